@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
@@ -9,17 +10,26 @@ import (
 )
 
 type AuthClient struct {
-	service  pb.AuthServiceClient
-	username string
-	password string
+	mutex        sync.Mutex
+	service      pb.AuthServiceClient
+	username     string
+	password     string
+	refreshToken string
 }
 
 func NewAuthClient(conn *grpc.ClientConn, username, password string) *AuthClient {
 	service := pb.NewAuthServiceClient(conn)
-	return &AuthClient{service, username, password}
+	return &AuthClient{service: service, username: username, password: password}
 }
 
 func (client *AuthClient) Login() (string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.login()
+}
+
+func (client *AuthClient) login() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -33,5 +43,54 @@ func (client *AuthClient) Login() (string, error) {
 		return "", err
 	}
 
+	client.refreshToken = resp.GetRefreshToken()
+	return resp.GetAccessToken(), nil
+}
+
+// Refresh returns a new access token, exchanging the refresh token of the last
+// login for it. It logs in again when there is no usable refresh token.
+func (client *AuthClient) Refresh() (string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.refreshToken == "" {
+		return client.login()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.service.RefreshToken(ctx, &pb.RefreshTokenRequest{
+		RefreshToken: client.refreshToken,
+	})
+	if err != nil {
+		client.refreshToken = ""
+		return client.login()
+	}
+
+	client.refreshToken = resp.GetRefreshToken()
 	return resp.GetAccessToken(), nil
 }
+
+// Logout revokes the tokens of the last login.
+func (client *AuthClient) Logout() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.refreshToken == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.service.Logout(ctx, &pb.LogoutRequest{
+		RefreshToken: client.refreshToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	client.refreshToken = ""
+	return nil
+}
@@ -9,20 +9,20 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// AuthInteceptor attaches the access token to every call, so the client
+// doesn't need to know the access policy of the server, which decides which
+// methods need it.
 type AuthInteceptor struct {
 	authClient  *AuthClient
-	authMethods map[string]bool
 	accessToken string
 }
 
 func NewAuthInterceptor(
 	authClient *AuthClient,
-	authMethods map[string]bool,
 	regreshDuration time.Duration,
 ) (*AuthInteceptor, error) {
 	interceptor := &AuthInteceptor{
-		authClient:  authClient,
-		authMethods: authMethods,
+		authClient: authClient,
 	}
 
 	err := interceptor.scheduleRefreshToken(regreshDuration)
@@ -44,11 +44,7 @@ func (interceptor *AuthInteceptor) Unary() grpc.UnaryClientInterceptor {
 		opts ...grpc.CallOption,
 	) error {
 		log.Printf("====== [Unary Interceptor] %s", method)
-		if interceptor.authMethods[method] {
-			return invoker(interceptor.attachToken(ctx), method, req, reply, cc, opts...)
-		}
-
-		return invoker(ctx, method, req, reply, cc, opts...)
+		return invoker(interceptor.attachToken(ctx), method, req, reply, cc, opts...)
 	}
 }
 
@@ -62,11 +58,7 @@ func (interceptor *AuthInteceptor) Stream() grpc.StreamClientInterceptor {
 		opts ...grpc.CallOption,
 	) (grpc.ClientStream, error) {
 		log.Printf("====== [Stream Interceptor] %s", method)
-		if interceptor.authMethods[method] {
-			return streamer(interceptor.attachToken(ctx), desc, cc, method, opts...)
-		}
-
-		return streamer(ctx, desc, cc, method, opts...)
+		return streamer(interceptor.attachToken(ctx), desc, cc, method, opts...)
 	}
 }
 
@@ -98,7 +90,7 @@ func (interceptor *AuthInteceptor) scheduleRefreshToken(refreshDuration time.Dur
 }
 
 func (interceptor *AuthInteceptor) refreshToken() error {
-	accessToken, err := interceptor.authClient.Login()
+	accessToken, err := interceptor.authClient.Refresh()
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,8 @@ package auth
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -81,33 +83,80 @@ func (laptopClient *LaptopClient) SearchLaptop(filter *pb.Filter) {
 	}
 }
 
+const maxUploadAttempts = 3
+
 func (laptopClient *LaptopClient) UploadImage(laptop *pb.Laptop, imagePath string) {
+	checksum, err := fileChecksum(imagePath)
+	if err != nil {
+		log.Fatal("cannot compute image checksum: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	upload, err := laptopClient.service.StartImageUpload(ctx, &pb.ImageInfo{
+		LaptopId:  laptop.GetId(),
+		ImageType: "jpg",
+		Sha256:    checksum,
+	})
+	if err != nil {
+		log.Fatal("cannot start image upload: ", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := laptopClient.uploadImageFrom(upload, imagePath)
+		if err == nil {
+			log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
+			return
+		}
+
+		if attempt == maxUploadAttempts {
+			log.Fatal("cannot upload image: ", err)
+		}
+
+		log.Printf("upload interrupted, resuming: %v", err)
+
+		upload, err = laptopClient.service.GetImageUploadStatus(ctx, &pb.GetImageUploadStatusRequest{
+			UploadId: upload.GetUploadId(),
+		})
+		if err != nil {
+			log.Fatal("cannot get image upload status: ", err)
+		}
+	}
+}
+
+func (laptopClient *LaptopClient) uploadImageFrom(upload *pb.ImageUploadStatus, imagePath string) (*pb.UploadImageResponse, error) {
 	file, err := os.Open(imagePath)
 	if err != nil {
-		log.Fatal("cannot open image file: ", err)
+		return nil, fmt.Errorf("cannot open image file: %w", err)
 	}
 	defer file.Close()
 
+	_, err = file.Seek(int64(upload.GetOffset()), io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("cannot seek image file: %w", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	stream, err := laptopClient.service.UploadImage(ctx)
 	if err != nil {
-		log.Fatal("cannot upload image: ", err)
+		return nil, fmt.Errorf("cannot upload image: %w", err)
 	}
 
 	req := &pb.UploadImageRequest{
 		Data: &pb.UploadImageRequest_Info{
 			Info: &pb.ImageInfo{
-				LaptopId:  laptop.GetId(),
-				ImageType: "jpg",
+				UploadId: upload.GetUploadId(),
+				Offset:   upload.GetOffset(),
 			},
 		},
 	}
 
 	err = stream.Send(req)
 	if err != nil {
-		log.Fatal("cannot send image info to server: ", err)
+		return nil, fmt.Errorf("cannot send image info to server: %v - %v", err, stream.RecvMsg(nil))
 	}
 
 	reader := bufio.NewReader(file)
@@ -120,7 +169,7 @@ func (laptopClient *LaptopClient) UploadImage(laptop *pb.Laptop, imagePath strin
 				break
 			}
 
-			log.Fatal("cannot read chunk to buffer: ", err)
+			return nil, fmt.Errorf("cannot read chunk to buffer: %w", err)
 		}
 
 		req := &pb.UploadImageRequest{
@@ -131,16 +180,27 @@ func (laptopClient *LaptopClient) UploadImage(laptop *pb.Laptop, imagePath strin
 
 		err = stream.Send(req)
 		if err != nil {
-			log.Fatal("cannot send chunk to server: ", err)
+			return nil, fmt.Errorf("cannot send chunk to server: %v - %v", err, stream.RecvMsg(nil))
 		}
 	}
 
-	res, err := stream.CloseAndRecv()
+	return stream.CloseAndRecv()
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
 	if err != nil {
-		log.Fatal("cannot receive response: ", err)
+		return "", err
 	}
 
-	log.Printf("image uploaded with id: %s, size: %d", res.GetId(), res.GetSize())
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 func (laptopClient *LaptopClient) RateLaptop(laptopIDs []string, scores []float64) error {
@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
@@ -73,19 +74,9 @@ func testRateLaptop(laptopClient *auth.LaptopClient) {
 }
 
 const (
-	username        = "admin1"
-	password        = "secret"
 	refreshDuration = 30 * time.Second
 )
 
-func accessibleRoles() map[string]bool {
-	return map[string]bool{
-		pb.LaptopService_CreateLaptop_FullMethodName: true,
-		pb.LaptopService_UploadImage_FullMethodName:  true,
-		pb.LaptopService_RateLaptop_FullMethodName:   true,
-	}
-}
-
 func loatTLSCertificates() (credentials.TransportCredentials, error) {
 	certPool, err := util.LoadCAPool()
 	if err != nil {
@@ -109,6 +100,7 @@ func main() {
 	serverAddress := flag.String("address", "", "the server address")
 	operation := flag.String("operation", "", "which operation to do")
 	enableTLS := flag.Bool("tls", false, "enable SSL/TLS")
+	username := flag.String("username", "admin1", "the user to log in as, with the password read from PASSWORD")
 	flag.Parse()
 	log.Printf("dial server %s, TLS: %t", *serverAddress, *enableTLS)
 
@@ -128,8 +120,8 @@ func main() {
 		log.Fatal("cannot dial server: ", err)
 	}
 
-	authClient := auth.NewAuthClient(cc1, username, password)
-	interceptor, err := auth.NewAuthInterceptor(authClient, accessibleRoles(), refreshDuration)
+	authClient := auth.NewAuthClient(cc1, *username, os.Getenv("PASSWORD"))
+	interceptor, err := auth.NewAuthInterceptor(authClient, refreshDuration)
 	if err != nil {
 		log.Fatal("cannot create auth interceptor: ", err)
 	}
@@ -157,4 +149,9 @@ func main() {
 	default:
 		log.Fatal("unknown operation")
 	}
+
+	err = authClient.Logout()
+	if err != nil {
+		log.Print("cannot log out: ", err)
+	}
 }
@@ -2,21 +2,38 @@ package main_test
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/sample"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/serializer"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service/interceptor"
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 func TestClientCreateLaptop(t *testing.T) {
@@ -65,7 +82,7 @@ func TestClientSearchLaptop(t *testing.T) {
 
 		switch i {
 		case 0:
-			laptop.PriceUsd = 2000
+			laptop.PriceUsd = 3500
 		case 1:
 			laptop.Cpu.NumberCores = 2
 		case 2:
@@ -124,16 +141,110 @@ func TestClientSearchLaptop(t *testing.T) {
 	require.Equal(t, len(expectedIDs), found)
 }
 
+func TestClientSearchLaptopOrder(t *testing.T) {
+	t.Parallel()
+
+	store := repository.NewInMemoryLaptopStore()
+	for i := 0; i < 20; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	serverAddress := startTestLaptopServer(t, store, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	search := func(filter *pb.Filter) []string {
+		stream, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{Filter: filter})
+		require.NoError(t, err)
+
+		ids := []string{}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return ids
+			}
+			require.NoError(t, err)
+			ids = append(ids, res.GetLaptop().GetId())
+		}
+	}
+
+	// both a full scan and an indexed search send the laptops in ID order
+	for _, filter := range []*pb.Filter{nil, {MaxPriceUsd: 2200}} {
+		ids := search(filter)
+		require.NotEmpty(t, ids)
+		require.True(t, sort.StringsAreSorted(ids))
+		require.Equal(t, ids, search(filter))
+	}
+}
+
+func TestClientSearchLaptopPages(t *testing.T) {
+	t.Parallel()
+
+	store := repository.NewInMemoryLaptopStore()
+	prices := []float64{1500, 2500, 1800, 2200, 1500, 2900, 1700}
+
+	for _, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.PriceUsd = price
+		err := store.Save(laptop)
+		require.NoError(t, err)
+	}
+
+	serverAddress := startTestLaptopServer(t, store, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	req := &pb.SearchLaptopRequest{
+		SortBy:    pb.SearchLaptopRequest_PRICE,
+		SortOrder: pb.SearchLaptopRequest_DESC,
+		PageSize:  3,
+	}
+
+	found := []float64{}
+	pages := 0
+
+	for {
+		stream, err := laptopClient.SearchLaptop(context.Background(), req)
+		require.NoError(t, err)
+		pages++
+
+		nextPageToken := ""
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			found = append(found, res.GetLaptop().GetPriceUsd())
+			nextPageToken = res.GetNextPageToken()
+		}
+
+		if nextPageToken == "" {
+			break
+		}
+		req.PageToken = nextPageToken
+	}
+
+	require.Equal(t, 3, pages)
+	require.Equal(t, []float64{2900, 2500, 2200, 1800, 1700, 1500, 1500}, found)
+
+	req.SortBy = pb.SearchLaptopRequest_RELEASE_YEAR
+	stream, err := laptopClient.SearchLaptop(context.Background(), req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestClientUploadImage(t *testing.T) {
 	t.Parallel()
 
 	testImageFolder := "../../tmp"
 
 	laptopStore := repository.NewInMemoryLaptopStore()
-	imageStore := repository.NewDiskImageStore(testImageFolder)
+	imageStore, err := repository.NewDiskImageStore(testImageFolder)
+	require.NoError(t, err)
 
 	laptop := sample.NewLaptop()
-	err := laptopStore.Save(laptop)
+	err = laptopStore.Save(laptop)
 	require.NoError(t, err)
 
 	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
@@ -186,77 +297,1376 @@ func TestClientUploadImage(t *testing.T) {
 	require.NotZero(t, res.GetId())
 	require.Equal(t, uint32(size), res.GetSize())
 
-	savedImagePath := fmt.Sprintf("%s/%s.%s", testImageFolder, res.GetId(), imageType)
-	require.FileExists(t, savedImagePath)
+	savedImage, err := imageStore.Find(res.GetId())
+	require.NoError(t, err)
+	require.Equal(t, testImageFolder, filepath.Dir(savedImage.Path))
+	require.FileExists(t, savedImage.Path)
 }
 
-func TestClientRateLaptop(t *testing.T) {
+func TestClientResumeUploadImage(t *testing.T) {
 	t.Parallel()
 
 	laptopStore := repository.NewInMemoryLaptopStore()
-	ratingStore := repository.NewInMemoryRatingStore()
+	imageFolder := t.TempDir()
+	imageStore, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
 
 	laptop := sample.NewLaptop()
-	err := laptopStore.Save(laptop)
+	err = laptopStore.Save(laptop)
 	require.NoError(t, err)
 
-	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
 	laptopClient := newTestLaptopClient(t, serverAddress)
 
-	stream, err := laptopClient.RateLaptop(context.Background())
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(imageData)
+	upload, err := laptopClient.StartImageUpload(context.Background(), &pb.ImageInfo{
+		LaptopId:  laptop.GetId(),
+		ImageType: "jpg",
+		Sha256:    hex.EncodeToString(sum[:]),
+	})
 	require.NoError(t, err)
+	require.NotEmpty(t, upload.GetUploadId())
+	require.Zero(t, upload.GetOffset())
 
-	scores := []float64{8, 7.5, 10}
-	averages := []float64{8, 7.75, 8.5}
+	half := len(imageData) / 2
 
-	n := len(scores)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := laptopClient.UploadImage(ctx)
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{UploadId: upload.GetUploadId()}, imageData[:half])
 
-	for i := 0; i < n; i++ {
-		req := &pb.RateLaptopRequest{
-			LaptopId: laptop.GetId(),
-			Score:    scores[i],
-		}
-		err := stream.Send(req)
+	require.Eventually(t, func() bool {
+		res, err := laptopClient.GetImageUploadStatus(context.Background(), &pb.GetImageUploadStatusRequest{
+			UploadId: upload.GetUploadId(),
+		})
+		return err == nil && res.GetOffset() == uint64(half)
+	}, time.Second, 10*time.Millisecond)
+
+	// only one stream at a time can continue the upload
+	stream, err = laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{UploadId: upload.GetUploadId(), Offset: uint64(half)}, nil)
+
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.Aborted, status.Code(err))
+	cancel()
+
+	// the server releases the upload once it notices the canceled stream
+	require.Eventually(t, func() bool {
+		stream, err := laptopClient.UploadImage(context.Background())
 		require.NoError(t, err)
+		sendImageChunks(t, stream, &pb.ImageInfo{UploadId: upload.GetUploadId(), Offset: 0}, nil)
+
+		_, err = stream.CloseAndRecv()
+		return status.Code(err) == codes.FailedPrecondition
+	}, time.Second, 10*time.Millisecond)
+
+	stream, err = laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{UploadId: upload.GetUploadId(), Offset: uint64(half)}, imageData[half:])
+
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, uint32(len(imageData)), res.GetSize())
+
+	reader, err := imageStore.Open(res.GetId(), "")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	savedData, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, imageData, savedData)
+
+	_, err = laptopClient.GetImageUploadStatus(context.Background(), &pb.GetImageUploadStatusRequest{
+		UploadId: upload.GetUploadId(),
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// the upload was staged in the image folder and moved into place
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, ".index.json", entries[0].Name())
+
+	info, err := imageStore.Find(res.GetId())
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(imageFolder, entries[1].Name()), info.Path)
+}
+
+func TestClientUploadImageChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	err = laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte("another image"))
+	stream, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{
+		LaptopId:  laptop.GetId(),
+		ImageType: "jpg",
+		Sha256:    hex.EncodeToString(sum[:]),
+	}, imageData)
+
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.DataLoss, status.Code(err))
+
+	images, err := imageStore.List(laptop.GetId())
+	require.NoError(t, err)
+	require.Empty(t, images)
+}
+
+func TestClientUploadImageContentType(t *testing.T) {
+	t.Parallel()
+
+	jpegData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	pngData := bytes.Buffer{}
+	err = png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		imageType   string
+		data        []byte
+		contentType string
+		code        codes.Code
+	}{
+		{
+			name:        "jpeg",
+			imageType:   "jpg",
+			data:        jpegData,
+			contentType: "image/jpeg",
+			code:        codes.OK,
+		},
+		{
+			name:        "png_mime_type",
+			imageType:   "image/png",
+			data:        pngData.Bytes(),
+			contentType: "image/png",
+			code:        codes.OK,
+		},
+		{
+			name:        "undeclared_type",
+			imageType:   "",
+			data:        pngData.Bytes(),
+			contentType: "image/png",
+			code:        codes.OK,
+		},
+		{
+			name:      "type_mismatch",
+			imageType: "png",
+			data:      jpegData,
+			code:      codes.InvalidArgument,
+		},
+		{
+			name:      "not_an_image",
+			imageType: "",
+			data:      []byte("#!/bin/sh\necho hello\n"),
+			code:      codes.InvalidArgument,
+		},
+		{
+			name:      "path_like_type",
+			imageType: "../../etc/passwd",
+			data:      jpegData,
+			code:      codes.InvalidArgument,
+		},
 	}
 
-	err = stream.CloseSend()
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptopStore := repository.NewInMemoryLaptopStore()
+			imageStore, err := repository.NewDiskImageStore(t.TempDir())
+			require.NoError(t, err)
+
+			laptop := sample.NewLaptop()
+			err = laptopStore.Save(laptop)
+			require.NoError(t, err)
+
+			serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
+			laptopClient := newTestLaptopClient(t, serverAddress)
+
+			stream, err := laptopClient.UploadImage(context.Background())
+			require.NoError(t, err)
+
+			sendImageChunks(t, stream, &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: tc.imageType}, tc.data)
+
+			res, err := stream.CloseAndRecv()
+			require.Equal(t, tc.code, status.Code(err))
+
+			images, err := imageStore.List(laptop.GetId())
+			require.NoError(t, err)
+
+			if tc.code != codes.OK {
+				require.Empty(t, images)
+				return
+			}
+
+			require.Len(t, images, 1)
+			require.Equal(t, res.GetId(), images[0].ID)
+			require.Equal(t, tc.contentType, images[0].ContentType)
+		})
+	}
+}
+
+func TestClientUploadImageMaxSize(t *testing.T) {
+	t.Parallel()
+
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		maxImageSize int64
+		code         codes.Code
+	}{
+		{
+			name:         "within_limit",
+			maxImageSize: int64(len(imageData)),
+			code:         codes.OK,
+		},
+		{
+			name:         "too_large",
+			maxImageSize: int64(len(imageData)) - 1,
+			code:         codes.InvalidArgument,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			laptopStore := repository.NewInMemoryLaptopStore()
+			imageStore, err := repository.NewDiskImageStore(t.TempDir())
+			require.NoError(t, err)
+
+			laptop := sample.NewLaptop()
+			err = laptopStore.Save(laptop)
+			require.NoError(t, err)
+
+			serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil,
+				service.WithMaxImageSize(tc.maxImageSize),
+				service.WithUploadStore(repository.NewDiskUploadStore(t.TempDir())),
+			)
+			laptopClient := newTestLaptopClient(t, serverAddress)
+
+			stream, err := laptopClient.UploadImage(context.Background())
+			require.NoError(t, err)
+
+			sendImageChunks(t, stream, &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: "jpg"}, imageData)
+
+			res, err := stream.CloseAndRecv()
+			require.Equal(t, tc.code, status.Code(err))
+
+			images, err := imageStore.List(laptop.GetId())
+			require.NoError(t, err)
+
+			if tc.code == codes.OK {
+				require.Equal(t, uint32(len(imageData)), res.GetSize())
+				require.Len(t, images, 1)
+			} else {
+				require.Empty(t, images)
+			}
+		})
+	}
+}
+
+// failingImageStore fails to save any image.
+type failingImageStore struct {
+	repository.ImageStore
+}
+
+func (store failingImageStore) Save(laptopID string, contentType string, imageData io.Reader) (string, error) {
+	return "", errors.New("disk is full")
+}
+
+func TestClientUploadImageSaveFailure(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	err = laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	uploadFolder := t.TempDir()
+	serverAddress := startTestLaptopServer(t, laptopStore, failingImageStore{imageStore}, nil,
+		service.WithUploadStore(repository.NewDiskUploadStore(uploadFolder)),
+	)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	stream, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: "jpg"}, imageData)
+
+	_, err = stream.CloseAndRecv()
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// the upload cannot be resumed, so nothing of it is left behind
+	entries, err := os.ReadDir(uploadFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestClientDownloadImage(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	err = laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	imageID, err := imageStore.Save(laptop.GetId(), "image/jpeg", bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	listRes, err := laptopClient.ListImages(context.Background(), &pb.ListImagesRequest{LaptopId: laptop.GetId()})
 	require.NoError(t, err)
+	require.Len(t, listRes.GetImages(), 1)
+	require.Equal(t, imageID, listRes.GetImages()[0].GetId())
+	require.Equal(t, uint32(len(imageData)), listRes.GetImages()[0].GetSize())
+	imageSum := sha256.Sum256(imageData)
+	require.Equal(t, hex.EncodeToString(imageSum[:]), listRes.GetImages()[0].GetSha256())
 
-	for idx := 0; ; idx++ {
+	stream, err := laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{ImageId: imageID})
+	require.NoError(t, err)
+
+	downloaded := bytes.Buffer{}
+	for {
 		res, err := stream.Recv()
 		if err == io.EOF {
-			require.Equal(t, n, idx)
 			break
 		}
 		require.NoError(t, err)
-		require.Equal(t, laptop.GetId(), res.GetLaptopId())
-		require.Equal(t, uint32(idx+1), res.GetRatedCount())
-		require.Equal(t, averages[idx], res.GetAverageScore())
+		require.Equal(t, "image/jpeg", res.GetContentType())
+		downloaded.Write(res.GetData())
 	}
+	require.Equal(t, imageData, downloaded.Bytes())
+
+	_, err = laptopClient.DeleteImage(context.Background(), &pb.DeleteImageRequest{ImageId: imageID})
+	require.NoError(t, err)
+
+	stream, err = laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{ImageId: imageID})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
 }
 
-func startTestLaptopServer(t *testing.T, laptopStore repository.LaptopStore, imageStore repository.ImageStore, ratingStore repository.RatingStore) string {
-	laptopServer := service.NewLaptopServer(
-		laptopStore, imageStore, ratingStore,
+func TestClientImageVariants(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	err = laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	imageData, err := os.ReadFile("../../tmp/angry-cat.jpg")
+	require.NoError(t, err)
+
+	original, err := jpeg.DecodeConfig(bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, nil,
+		service.WithImageVariants(
+			service.ImageVariant{Name: "thumbnail", Size: 32},
+			service.ImageVariant{Name: "medium", Size: 128},
+		),
 	)
+	laptopClient := newTestLaptopClient(t, serverAddress)
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	stream, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	sendImageChunks(t, stream, &pb.ImageInfo{LaptopId: laptop.GetId(), ImageType: "jpg"}, imageData)
 
-	listener, err := net.Listen("tcp", ":0")
+	uploadRes, err := stream.CloseAndRecv()
 	require.NoError(t, err)
 
-	go grpcServer.Serve(listener)
+	listRes, err := laptopClient.ListImages(context.Background(), &pb.ListImagesRequest{LaptopId: laptop.GetId()})
+	require.NoError(t, err)
+	require.Len(t, listRes.GetImages(), 1)
 
-	return listener.Addr().String()
+	variants := listRes.GetImages()[0].GetVariants()
+	require.Len(t, variants, 2)
+	require.Equal(t, "medium", variants[0].GetName())
+	require.Equal(t, "thumbnail", variants[1].GetName())
+
+	for _, variant := range variants {
+		size := uint32(32)
+		if variant.GetName() == "medium" {
+			size = 128
+		}
+
+		require.Equal(t, "image/jpeg", variant.GetContentType())
+		if variant.GetWidth() >= variant.GetHeight() {
+			require.Equal(t, size, variant.GetWidth())
+		} else {
+			require.Equal(t, size, variant.GetHeight())
+		}
+		require.InDelta(t,
+			float64(original.Width)/float64(original.Height),
+			float64(variant.GetWidth())/float64(variant.GetHeight()),
+			0.1,
+		)
+
+		stream, err := laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{
+			ImageId: uploadRes.GetId(),
+			Variant: variant.GetName(),
+		})
+		require.NoError(t, err)
+
+		downloaded := bytes.Buffer{}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			downloaded.Write(res.GetData())
+		}
+		require.Equal(t, variant.GetSize(), uint32(downloaded.Len()))
+
+		config, err := jpeg.DecodeConfig(&downloaded)
+		require.NoError(t, err)
+		require.Equal(t, variant.GetWidth(), uint32(config.Width))
+		require.Equal(t, variant.GetHeight(), uint32(config.Height))
+	}
+
+	downloadStream, err := laptopClient.DownloadImage(context.Background(), &pb.DownloadImageRequest{
+		ImageId: uploadRes.GetId(),
+		Variant: "huge",
+	})
+	require.NoError(t, err)
+	_, err = downloadStream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
 }
 
-func newTestLaptopClient(t *testing.T, serverAddress string) pb.LaptopServiceClient {
-	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+func TestClientRateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	ratingStore := repository.NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
 	require.NoError(t, err)
 
-	return pb.NewLaptopServiceClient(conn)
+	serverAddress, jwtManager := startTestAuthLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name    string
+		user    string
+		score   float64
+		count   uint32
+		average float64
+	}{
+		{"first user", "user1", 8, 1, 8},
+		{"second user", "user2", 7.5, 2, 7.75},
+		{"third user", "user3", 10, 3, 8.5},
+		{"re-rating", "user1", 2, 3, 6.5},
+	}
+
+	for _, tc := range testCases {
+		ctx := newTestUserContext(t, jwtManager, tc.user, "user")
+		res := rateTestLaptop(t, laptopClient, ctx, laptop.GetId(), tc.score)
+		require.Equal(t, laptop.GetId(), res.GetLaptopId(), tc.name)
+		require.Equal(t, tc.count, res.GetRatedCount(), tc.name)
+		require.Equal(t, tc.average, res.GetAverageScore(), tc.name)
+	}
+
+	ratingRes, err := laptopClient.GetLaptopRating(context.Background(), &pb.GetLaptopRatingRequest{
+		LaptopId: laptop.GetId(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), ratingRes.GetRatedCount())
+	require.Equal(t, 6.5, ratingRes.GetAverageScore())
+	require.Len(t, ratingRes.GetHistogram(), service.DEFAULT_MAX_SCORE-service.DEFAULT_MIN_SCORE+1)
+
+	histogram := make(map[int32]uint32)
+	for _, bucket := range ratingRes.GetHistogram() {
+		histogram[bucket.GetScore()] = bucket.GetCount()
+	}
+	require.Equal(t, uint32(1), histogram[2])
+	require.Equal(t, uint32(1), histogram[7])
+	require.Equal(t, uint32(0), histogram[8])
+	require.Equal(t, uint32(1), histogram[10])
+
+	_, err = laptopClient.GetLaptopRating(context.Background(), &pb.GetLaptopRatingRequest{
+		LaptopId: sample.NewLaptop().GetId(),
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientRateLaptopInvalid(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	ratingStore := repository.NewInMemoryRatingStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress, jwtManager := startTestAuthLaptopServer(t, laptopStore, nil, ratingStore,
+		service.WithScoreRange(1, 5),
+	)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		score float64
+		code  codes.Code
+	}{
+		{"too low", newTestUserContext(t, jwtManager, "user1", "user"), 0.5, codes.InvalidArgument},
+		{"too high", newTestUserContext(t, jwtManager, "user1", "user"), 1e9, codes.InvalidArgument},
+		{"not a number", newTestUserContext(t, jwtManager, "user1", "user"), math.NaN(), codes.InvalidArgument},
+		{"infinite", newTestUserContext(t, jwtManager, "user1", "user"), math.Inf(1), codes.InvalidArgument},
+		{"anonymous", context.Background(), 3, codes.Unauthenticated},
+	}
+
+	for _, tc := range testCases {
+		stream, err := laptopClient.RateLaptop(tc.ctx)
+		require.NoError(t, err, tc.name)
+
+		err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.GetId(), Score: tc.score})
+		if err == nil {
+			_, err = stream.Recv()
+		} else {
+			err = stream.RecvMsg(nil)
+		}
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+
+	rating, err := ratingStore.Find(laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, rating)
+}
+
+func TestClientTopRatedLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	ratingStore := repository.NewInMemoryRatingStore()
+
+	laptops := make([]*pb.Laptop, 4)
+	for i := range laptops {
+		laptops[i] = sample.NewLaptop()
+		laptops[i].PriceUsd = 1000
+		if i == 3 {
+			laptops[i].PriceUsd = 5000
+		}
+		err := laptopStore.Save(laptops[i])
+		require.NoError(t, err)
+	}
+
+	ratings := []struct {
+		laptop int
+		user   string
+		score  float64
+	}{
+		{0, "user1", 6}, {0, "user2", 8},
+		{1, "user1", 9},
+		{2, "user1", 4}, {2, "user2", 5},
+		{3, "user1", 10}, {3, "user2", 10},
+	}
+	for _, rating := range ratings {
+		_, err := ratingStore.Add(laptops[rating.laptop].GetId(), rating.user, rating.score)
+		require.NoError(t, err)
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, ratingStore)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	testCases := []struct {
+		name     string
+		req      *pb.TopRatedLaptopsRequest
+		expected []int
+	}{
+		{"every laptop", &pb.TopRatedLaptopsRequest{}, []int{3, 1, 0, 2}},
+		{"filtered", &pb.TopRatedLaptopsRequest{Filter: &pb.Filter{MaxPriceUsd: 2000}}, []int{1, 0, 2}},
+		{"limited", &pb.TopRatedLaptopsRequest{Filter: &pb.Filter{MaxPriceUsd: 2000}, Limit: 2}, []int{1, 0}},
+		{"min rated count", &pb.TopRatedLaptopsRequest{MinRatedCount: 2}, []int{3, 0, 2}},
+	}
+
+	for _, tc := range testCases {
+		stream, err := laptopClient.TopRatedLaptops(context.Background(), tc.req)
+		require.NoError(t, err, tc.name)
+
+		found := []string{}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, tc.name)
+			found = append(found, res.GetLaptop().GetId())
+
+			if res.GetLaptop().GetId() == laptops[0].GetId() {
+				require.Equal(t, uint32(2), res.GetRatedCount(), tc.name)
+				require.Equal(t, 7.0, res.GetAverageScore(), tc.name)
+				require.Equal(t, 1.0, res.GetScoreStddev(), tc.name)
+			}
+		}
+
+		expected := []string{}
+		for _, i := range tc.expected {
+			expected = append(expected, laptops[i].GetId())
+		}
+		require.Equal(t, expected, found, tc.name)
+	}
+}
+
+func TestClientReviews(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	reviewStore := repository.NewInMemoryReviewStore()
+
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress, jwtManager := startTestReviewServer(t, reviewStore, laptopStore)
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	reviewClient := pb.NewReviewServiceClient(conn)
+
+	userCtx := newTestUserContext(t, jwtManager, "user1", "user")
+	adminCtx := newTestUserContext(t, jwtManager, "admin1", "admin")
+
+	createdIDs := []string{}
+	for i := 0; i < 3; i++ {
+		res, err := reviewClient.CreateReview(userCtx, &pb.CreateReviewRequest{
+			LaptopId: laptop.GetId(),
+			Title:    fmt.Sprintf("review %d", i),
+			Text:     "fast and light",
+			Pros:     []string{"battery"},
+			Cons:     []string{"keyboard"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "user1", res.GetReview().GetAuthor())
+		require.NotNil(t, res.GetReview().GetCreatedAt())
+		createdIDs = append(createdIDs, res.GetReview().GetId())
+	}
+
+	createTestCases := []struct {
+		name string
+		ctx  context.Context
+		req  *pb.CreateReviewRequest
+		code codes.Code
+	}{
+		{"anonymous", context.Background(), &pb.CreateReviewRequest{LaptopId: laptop.GetId(), Title: "title", Text: "text"}, codes.Unauthenticated},
+		{"no title", userCtx, &pb.CreateReviewRequest{LaptopId: laptop.GetId(), Text: "text"}, codes.InvalidArgument},
+		{"no text", userCtx, &pb.CreateReviewRequest{LaptopId: laptop.GetId(), Title: "title"}, codes.InvalidArgument},
+		{"empty con", userCtx, &pb.CreateReviewRequest{LaptopId: laptop.GetId(), Title: "title", Text: "text", Cons: []string{" "}}, codes.InvalidArgument},
+		{"unknown laptop", userCtx, &pb.CreateReviewRequest{LaptopId: sample.NewLaptop().GetId(), Title: "title", Text: "text"}, codes.NotFound},
+	}
+
+	for _, tc := range createTestCases {
+		_, err := reviewClient.CreateReview(tc.ctx, tc.req)
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+
+	_, err = reviewClient.ModerateReview(userCtx, &pb.ModerateReviewRequest{Id: createdIDs[1], Hidden: true})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	moderateRes, err := reviewClient.ModerateReview(adminCtx, &pb.ModerateReviewRequest{Id: createdIDs[1], Hidden: true})
+	require.NoError(t, err)
+	require.True(t, moderateRes.GetReview().GetHidden())
+
+	_, err = reviewClient.ModerateReview(adminCtx, &pb.ModerateReviewRequest{Id: "unknown", Hidden: true})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	foundIDs := []string{}
+	pageToken := ""
+	for pages := 1; ; pages++ {
+		res, err := reviewClient.ListReviews(context.Background(), &pb.ListReviewsRequest{
+			LaptopId:  laptop.GetId(),
+			PageSize:  1,
+			PageToken: pageToken,
+		})
+		require.NoError(t, err)
+		require.Len(t, res.GetReviews(), 1)
+		foundIDs = append(foundIDs, res.GetReviews()[0].GetId())
+
+		pageToken = res.GetNextPageToken()
+		if pageToken == "" {
+			require.Equal(t, 2, pages)
+			break
+		}
+	}
+
+	// newest first, without the hidden review
+	require.Equal(t, []string{createdIDs[2], createdIDs[0]}, foundIDs)
+
+	listTestCases := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"anonymous", context.Background(), codes.Unauthenticated},
+		{"user", userCtx, codes.PermissionDenied},
+		{"admin", adminCtx, codes.OK},
+	}
+
+	for _, tc := range listTestCases {
+		res, err := reviewClient.ListReviews(tc.ctx, &pb.ListReviewsRequest{
+			LaptopId:      laptop.GetId(),
+			IncludeHidden: true,
+		})
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+		if err == nil {
+			require.Equal(t, []string{createdIDs[2], createdIDs[1], createdIDs[0]}, reviewIDs(res.GetReviews()))
+		}
+	}
+
+	// the pros and cons are stored trimmed, like the title and text
+	res, err := reviewClient.CreateReview(userCtx, &pb.CreateReviewRequest{
+		LaptopId: laptop.GetId(),
+		Title:    " trimmed ",
+		Text:     " text ",
+		Pros:     []string{" battery "},
+		Cons:     []string{"\tkeyboard\n"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "trimmed", res.GetReview().GetTitle())
+	require.Equal(t, []string{"battery"}, res.GetReview().GetPros())
+	require.Equal(t, []string{"keyboard"}, res.GetReview().GetCons())
+
+	saved, err := reviewStore.Find(res.GetReview().GetId())
+	require.NoError(t, err)
+	require.Equal(t, []string{"battery"}, saved.GetPros())
+}
+
+func reviewIDs(reviews []*pb.Review) []string {
+	ids := []string{}
+	for _, review := range reviews {
+		ids = append(ids, review.GetId())
+	}
+
+	return ids
+}
+
+func TestClientUserManagement(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+	admin, err := entity.NewUser("admin1", "Secret123", "admin")
+	require.NoError(t, err)
+	err = userStore.Save(admin)
+	require.NoError(t, err)
+
+	serverAddress, jwtManager := startTestUserServer(t, userStore)
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	authClient := pb.NewAuthServiceClient(conn)
+
+	registerTestCases := []struct {
+		name     string
+		username string
+		password string
+		code     codes.Code
+	}{
+		{"valid", "alice", "Laptop2023", codes.OK},
+		{"another valid", "bob", "Keyboard99", codes.OK},
+		{"taken username", "alice", "Laptop2023", codes.AlreadyExists},
+		{"short password", "carol", "Ab1", codes.InvalidArgument},
+		{"no digit", "carol", "LaptopLaptop", codes.InvalidArgument},
+		{"no uppercase", "carol", "laptop2023", codes.InvalidArgument},
+		{"contains username", "carol", "Carol12345", codes.InvalidArgument},
+		{"invalid username", "carol smith", "Laptop2023", codes.InvalidArgument},
+	}
+
+	for _, tc := range registerTestCases {
+		res, err := authClient.Register(context.Background(), &pb.RegisterRequest{
+			Username: tc.username,
+			Password: tc.password,
+		})
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+		if tc.code == codes.OK {
+			require.Equal(t, "user", res.GetUser().GetRole(), tc.name)
+		}
+	}
+
+	_, err = authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+
+	aliceCtx := newTestUserContext(t, jwtManager, "alice", "user")
+	adminCtx := newTestUserContext(t, jwtManager, "admin1", "admin")
+
+	_, err = authClient.ChangePassword(aliceCtx, &pb.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "Notebook42"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = authClient.ChangePassword(aliceCtx, &pb.ChangePasswordRequest{OldPassword: "Laptop2023", NewPassword: "weak"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = authClient.ChangePassword(aliceCtx, &pb.ChangePasswordRequest{OldPassword: "Laptop2023", NewPassword: "Notebook42"})
+	require.NoError(t, err)
+
+	_, err = authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Notebook42"})
+	require.NoError(t, err)
+
+	_, err = authClient.ListUsers(aliceCtx, &pb.ListUsersRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	listRes, err := authClient.ListUsers(adminCtx, &pb.ListUsersRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, listRes.GetUsers(), 2)
+	require.Equal(t, "admin1", listRes.GetUsers()[0].GetUsername())
+	require.Equal(t, "alice", listRes.GetUsers()[1].GetUsername())
+
+	listRes, err = authClient.ListUsers(adminCtx, &pb.ListUsersRequest{PageSize: 2, PageToken: listRes.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, listRes.GetUsers(), 1)
+	require.Equal(t, "bob", listRes.GetUsers()[0].GetUsername())
+	require.Empty(t, listRes.GetNextPageToken())
+
+	roleTestCases := []struct {
+		name string
+		ctx  context.Context
+		req  *pb.UpdateUserRoleRequest
+		code codes.Code
+	}{
+		{"not an admin", aliceCtx, &pb.UpdateUserRoleRequest{Username: "bob", Role: "admin"}, codes.PermissionDenied},
+		{"unknown role", adminCtx, &pb.UpdateUserRoleRequest{Username: "bob", Role: "root"}, codes.InvalidArgument},
+		{"unknown user", adminCtx, &pb.UpdateUserRoleRequest{Username: "dave", Role: "admin"}, codes.NotFound},
+		{"own role", adminCtx, &pb.UpdateUserRoleRequest{Username: "admin1", Role: "user"}, codes.FailedPrecondition},
+		{"promotion", adminCtx, &pb.UpdateUserRoleRequest{Username: "bob", Role: "admin"}, codes.OK},
+	}
+
+	for _, tc := range roleTestCases {
+		_, err := authClient.UpdateUserRole(tc.ctx, tc.req)
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+
+	bob, err := userStore.Find("bob")
+	require.NoError(t, err)
+	require.Equal(t, "admin", bob.Role)
+
+	_, err = authClient.DeleteUser(aliceCtx, &pb.DeleteUserRequest{Username: "bob"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = authClient.DeleteUser(adminCtx, &pb.DeleteUserRequest{Username: "admin1"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = authClient.DeleteUser(adminCtx, &pb.DeleteUserRequest{Username: "bob"})
+	require.NoError(t, err)
+
+	_, err = authClient.DeleteUser(adminCtx, &pb.DeleteUserRequest{Username: "bob"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+	user, err := entity.NewUser("alice", "Laptop2023", "user")
+	require.NoError(t, err)
+	err = userStore.Save(user)
+	require.NoError(t, err)
+
+	serverAddress, _ := startTestUserServer(t, userStore)
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	authClient := pb.NewAuthServiceClient(conn)
+
+	loginRes, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+	require.NotEmpty(t, loginRes.GetRefreshToken())
+
+	// a wrong old password tells the accepted tokens from the rejected ones
+	// without changing the password, which would end the sessions
+	passwordReq := &pb.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "Laptop2023"}
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+	}
+
+	_, err = authClient.ChangePassword(withToken(loginRes.GetAccessToken()), passwordReq)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = authClient.ChangePassword(withToken(loginRes.GetRefreshToken()), passwordReq)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	refreshRes, err := authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{
+		RefreshToken: loginRes.GetRefreshToken(),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, loginRes.GetRefreshToken(), refreshRes.GetRefreshToken())
+
+	_, err = authClient.ChangePassword(withToken(refreshRes.GetAccessToken()), passwordReq)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = authClient.Logout(context.Background(), &pb.LogoutRequest{RefreshToken: refreshRes.GetRefreshToken()})
+	require.NoError(t, err)
+
+	_, err = authClient.ChangePassword(withToken(refreshRes.GetAccessToken()), passwordReq)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = authClient.ChangePassword(withToken(loginRes.GetAccessToken()), passwordReq)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{
+		RefreshToken: refreshRes.GetRefreshToken(),
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	loginRes, err = authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+
+	err = userStore.Delete("alice")
+	require.NoError(t, err)
+
+	_, err = authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{
+		RefreshToken: loginRes.GetRefreshToken(),
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestClientRefreshTokenAfterPasswordChange(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+	user, err := entity.NewUser("alice", "Laptop2023", "user")
+	require.NoError(t, err)
+	err = userStore.Save(user)
+	require.NoError(t, err)
+
+	serverAddress, _ := startTestUserServer(t, userStore)
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	authClient := pb.NewAuthServiceClient(conn)
+
+	stolenRes, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+	loginRes, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", loginRes.GetAccessToken())
+	_, err = authClient.ChangePassword(ctx, &pb.ChangePasswordRequest{OldPassword: "Laptop2023", NewPassword: "Notebook42"})
+	require.NoError(t, err)
+
+	for _, refreshToken := range []string{stolenRes.GetRefreshToken(), loginRes.GetRefreshToken()} {
+		_, err = authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{RefreshToken: refreshToken})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+
+	loginRes, err = authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Notebook42"})
+	require.NoError(t, err)
+
+	_, err = authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{RefreshToken: loginRes.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestClientConcurrentRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+	user, err := entity.NewUser("alice", "Laptop2023", "user")
+	require.NoError(t, err)
+	err = userStore.Save(user)
+	require.NoError(t, err)
+
+	serverAddress, _ := startTestUserServer(t, userStore)
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+	authClient := pb.NewAuthServiceClient(conn)
+
+	loginRes, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: "alice", Password: "Laptop2023"})
+	require.NoError(t, err)
+
+	// only one of the requests racing with the same refresh token gets new
+	// tokens, and the reuse revokes the session of those as well
+	results := make(chan *pb.RefreshTokenResponse, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			res, _ := authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{
+				RefreshToken: loginRes.GetRefreshToken(),
+			})
+			results <- res
+		}()
+	}
+
+	refreshed := []*pb.RefreshTokenResponse{}
+	for i := 0; i < cap(results); i++ {
+		if res := <-results; res != nil {
+			refreshed = append(refreshed, res)
+		}
+	}
+	require.Len(t, refreshed, 1)
+
+	_, err = authClient.RefreshToken(context.Background(), &pb.RefreshTokenRequest{
+		RefreshToken: refreshed[0].GetRefreshToken(),
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestClientLoginLockout(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+	for _, username := range []string{"alice", "bob"} {
+		user, err := entity.NewUser(username, "Laptop2023", "user")
+		require.NoError(t, err)
+		err = userStore.Save(user)
+		require.NoError(t, err)
+	}
+
+	loginLimiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(3, 5),
+		service.WithLoginLockout(time.Minute, time.Hour),
+	)
+	serverAddress, jwtManager := startTestAuthServer(t, func(grpcServer *grpc.Server, jwtManager *service.JWTManager) {
+		pb.RegisterAuthServiceServer(grpcServer, service.NewAuthServer(userStore, jwtManager, service.WithLoginLimiter(loginLimiter)))
+	})
+	// dial over IPv4 so that the server sees the client IP unlocked below
+	_, port, err := net.SplitHostPort(serverAddress)
+	require.NoError(t, err)
+	conn, err := grpc.Dial(net.JoinHostPort("127.0.0.1", port), grpc.WithInsecure())
+	require.NoError(t, err)
+	authClient := pb.NewAuthServiceClient(conn)
+
+	login := func(username string, password string) error {
+		_, err := authClient.Login(context.Background(), &pb.LoginRequest{Username: username, Password: password})
+		return err
+	}
+
+	require.Equal(t, codes.NotFound, status.Code(login("alice", "wrong")))
+	require.Equal(t, codes.NotFound, status.Code(login("alice", "wrong")))
+
+	err = login("alice", "wrong")
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	retryInfo, ok := details[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.Equal(t, time.Minute, retryInfo.GetRetryDelay().AsDuration())
+
+	// the right password doesn't help while locked out
+	require.Equal(t, codes.ResourceExhausted, status.Code(login("alice", "Laptop2023")))
+	require.NoError(t, login("bob", "Laptop2023"))
+
+	_, err = authClient.UnlockUser(newTestUserContext(t, jwtManager, "bob", "user"), &pb.UnlockUserRequest{Username: "alice"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	adminCtx := newTestUserContext(t, jwtManager, "admin1", "admin")
+	_, err = authClient.UnlockUser(adminCtx, &pb.UnlockUserRequest{Username: "carol"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err := authClient.UnlockUser(adminCtx, &pb.UnlockUserRequest{Username: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", res.GetUsername())
+
+	require.NoError(t, login("alice", "Laptop2023"))
+
+	_, err = authClient.UnlockUser(adminCtx, &pb.UnlockUserRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = authClient.UnlockUser(adminCtx, &pb.UnlockUserRequest{Ip: "not-an-ip"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the IP got the failures of both users, so it's locked out for all of them
+	require.Equal(t, codes.NotFound, status.Code(login("bob", "wrong")))
+	require.Equal(t, codes.ResourceExhausted, status.Code(login("bob", "wrong")))
+	require.Equal(t, codes.ResourceExhausted, status.Code(login("bob", "Laptop2023")))
+
+	res, err = authClient.UnlockUser(adminCtx, &pb.UnlockUserRequest{Ip: "127.0.0.1"})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", res.GetIp())
+
+	require.NoError(t, login("bob", "Laptop2023"))
+}
+
+func TestClientUpdateLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	req := &pb.UpdateLaptopRequest{
+		Laptop: &pb.Laptop{
+			Id:       laptop.GetId(),
+			PriceUsd: 999,
+			Cpu:      &pb.CPU{NumberCores: 16},
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"price_usd", "cpu.number_cores"}},
+	}
+
+	res, err := laptopClient.UpdateLaptop(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, float64(999), res.GetLaptop().GetPriceUsd())
+	require.Equal(t, uint32(16), res.GetLaptop().GetCpu().GetNumberCores())
+	require.Equal(t, laptop.GetCpu().GetName(), res.GetLaptop().GetCpu().GetName())
+	require.Equal(t, laptop.GetBrand(), res.GetLaptop().GetBrand())
+
+	other, err := laptopStore.Find(laptop.GetId())
+	require.NoError(t, err)
+	requireSameLaptop(t, res.GetLaptop(), other)
+
+	req.UpdateMask.Paths = []string{"id"}
+	_, err = laptopClient.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.Laptop.Id = sample.NewLaptop().GetId()
+	req.UpdateMask.Paths = []string{"price_usd"}
+	_, err = laptopClient.UpdateLaptop(context.Background(), req)
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestClientDeleteLaptop(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	err := laptopStore.Save(laptop)
+	require.NoError(t, err)
+
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+	imageID, err := imageStore.Save(laptop.GetId(), "image/jpeg", bytes.NewReader([]byte("a photo")))
+	require.NoError(t, err)
+
+	ratingStore := repository.NewInMemoryRatingStore()
+	_, err = ratingStore.Add(laptop.GetId(), "alice", 5)
+	require.NoError(t, err)
+
+	reviewStore := repository.NewInMemoryReviewStore()
+	err = reviewStore.Save(&pb.Review{Id: "review-1", LaptopId: laptop.GetId(), Author: "alice", Title: "good", Text: "fast"})
+	require.NoError(t, err)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, imageStore, ratingStore, service.WithReviewStore(reviewStore))
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	res, err := laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), res.GetId())
+
+	_, err = laptopClient.GetLaptop(context.Background(), &pb.GetLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// the images, the rating and the reviews of the laptop are deleted with it
+	image, err := imageStore.Find(imageID)
+	require.NoError(t, err)
+	require.Nil(t, image)
+
+	rating, err := ratingStore.Find(laptop.GetId())
+	require.NoError(t, err)
+	require.Nil(t, rating)
+
+	review, err := reviewStore.Find("review-1")
+	require.NoError(t, err)
+	require.Nil(t, review)
+}
+
+func TestClientListLaptops(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := repository.NewInMemoryLaptopStore()
+	expectedIDs := make(map[string]bool)
+
+	for i := 0; i < 7; i++ {
+		laptop := sample.NewLaptop()
+		err := laptopStore.Save(laptop)
+		require.NoError(t, err)
+		expectedIDs[laptop.GetId()] = true
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	foundIDs := make(map[string]bool)
+	pages := 0
+	req := &pb.ListLaptopsRequest{PageSize: 3}
+
+	for {
+		res, err := laptopClient.ListLaptops(context.Background(), req)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(res.GetLaptops()), 3)
+		pages++
+
+		for _, laptop := range res.GetLaptops() {
+			require.Contains(t, expectedIDs, laptop.GetId())
+			require.NotContains(t, foundIDs, laptop.GetId())
+			foundIDs[laptop.GetId()] = true
+		}
+
+		if res.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+
+	require.Equal(t, 3, pages)
+	require.Equal(t, expectedIDs, foundIDs)
+}
+
+func startTestLaptopServer(t *testing.T, laptopStore repository.LaptopStore, imageStore repository.ImageStore, ratingStore repository.RatingStore, opts ...service.LaptopServerOption) string {
+	laptopServer := service.NewLaptopServer(
+		laptopStore, imageStore, ratingStore, opts...,
+	)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	go grpcServer.Serve(listener)
+
+	return listener.Addr().String()
+}
+
+// startTestAuthLaptopServer starts a laptop server that authenticates its
+// callers, so RPCs that depend on the user get their claims.
+func startTestAuthLaptopServer(t *testing.T, laptopStore repository.LaptopStore, imageStore repository.ImageStore, ratingStore repository.RatingStore, opts ...service.LaptopServerOption) (string, *service.JWTManager) {
+	laptopServer := service.NewLaptopServer(
+		laptopStore, imageStore, ratingStore, opts...,
+	)
+
+	return startTestAuthServer(t, func(grpcServer *grpc.Server, _ *service.JWTManager) {
+		pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	})
+}
+
+func startTestReviewServer(t *testing.T, reviewStore repository.ReviewStore, laptopStore repository.LaptopStore) (string, *service.JWTManager) {
+	policy, err := interceptor.LoadPolicy("../../config/rbac.yaml")
+	require.NoError(t, err)
+	reviewServer := service.NewReviewServer(reviewStore, laptopStore, service.WithPermissionChecker(policy.HasPermission))
+
+	return startTestAuthServer(t, func(grpcServer *grpc.Server, _ *service.JWTManager) {
+		pb.RegisterReviewServiceServer(grpcServer, reviewServer)
+	})
+}
+
+func startTestUserServer(t *testing.T, userStore repository.UserStore) (string, *service.JWTManager) {
+	return startTestAuthServer(t, func(grpcServer *grpc.Server, jwtManager *service.JWTManager) {
+		pb.RegisterAuthServiceServer(grpcServer, service.NewAuthServer(userStore, jwtManager))
+	})
+}
+
+func startTestAuthServer(t *testing.T, register func(grpcServer *grpc.Server, jwtManager *service.JWTManager)) (string, *service.JWTManager) {
+	jwtManager := service.NewJWTManager("test-secret", time.Minute)
+	policy, err := interceptor.LoadPolicy("../../config/rbac.yaml")
+	require.NoError(t, err)
+	authInterceptor := interceptor.NewAuthInterceptor(jwtManager, policy)
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(authInterceptor.Unary()),
+		grpc.StreamInterceptor(authInterceptor.Stream()),
+	)
+	register(grpcServer, jwtManager)
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return listener.Addr().String(), jwtManager
+}
+
+func newTestUserContext(t *testing.T, jwtManager *service.JWTManager, username string, role string) context.Context {
+	token, err := jwtManager.Generate(&entity.User{Username: username, Role: role})
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+func rateTestLaptop(t *testing.T, laptopClient pb.LaptopServiceClient, ctx context.Context, laptopID string, score float64) *pb.RateLaptopResponse {
+	stream, err := laptopClient.RateLaptop(ctx)
+	require.NoError(t, err)
+
+	err = stream.Send(&pb.RateLaptopRequest{LaptopId: laptopID, Score: score})
+	require.NoError(t, err)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+
+	err = stream.CloseSend()
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	require.Equal(t, io.EOF, err)
+
+	return res
+}
+
+func newTestLaptopClient(t *testing.T, serverAddress string) pb.LaptopServiceClient {
+	conn, err := grpc.Dial(serverAddress, grpc.WithInsecure())
+	require.NoError(t, err)
+
+	return pb.NewLaptopServiceClient(conn)
+}
+
+func sendImageChunks(t *testing.T, stream pb.LaptopService_UploadImageClient, info *pb.ImageInfo, data []byte) {
+	err := stream.Send(&pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{Info: info},
+	})
+	require.NoError(t, err)
+
+	for len(data) > 0 {
+		n := len(data)
+		if n > 1024 {
+			n = 1024
+		}
+		err := stream.Send(&pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: data[:n]},
+		})
+		if err == io.EOF {
+			// the server closed the stream, its status is returned by CloseAndRecv
+			return
+		}
+		require.NoError(t, err)
+		data = data[n:]
+	}
 }
 
 func requireSameLaptop(t *testing.T, laptop1, laptop2 *pb.Laptop) {
@@ -3,12 +3,22 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/cmd/util"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
@@ -17,45 +27,229 @@ import (
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service/interceptor"
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 const (
-	secretKey      = "secret"
 	tokenDuration  = 15 * time.Minute
 	serverCertFile = "cert/server-cert.pem"
 	serverKeyFile  = "cert/server-key.pem"
+
+	inProcessBufferSize = 1 << 20
 )
 
-func createUser(userStore repository.UserStore, username, pasword, role string) error {
-	user, err := entity.NewUser(username, pasword, role)
+// seedAdmin creates the first admin, so that there is someone to register the
+// other users. Nothing is created without a password, which must pass the
+// password policy like any other.
+func seedAdmin(userStore repository.UserStore, username string, password string) error {
+	if password == "" {
+		log.Printf("no admin created, set ADMIN_PASSWORD to create %s", username)
+		return nil
+	}
+
+	err := entity.ValidatePassword(username, password)
+	if err != nil {
+		return fmt.Errorf("invalid ADMIN_PASSWORD: %w", err)
+	}
+
+	user, err := entity.NewUser(username, password, entity.ROLE_ADMIN)
 	if err != nil {
-		log.Fatal("cannot create user: ", err)
+		return fmt.Errorf("cannot create user: %w", err)
+	}
+
+	err = userStore.Save(user)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		// the admin of a persistent store is only created once, and keeps the
+		// password it was given then
+		return nil
 	}
 
-	return userStore.Save(user)
+	return err
 }
 
-func seedUsers(userStore repository.UserStore) error {
-	err := createUser(userStore, "admin1", "secret", "admin")
-	if err != nil {
-		return err
+// reloadPolicyOnSignal reloads the access policy on SIGHUP. An invalid policy
+// file is logged and the current policy is kept.
+func reloadPolicyOnSignal(policyFile string, authInterceptor *interceptor.AuthInterceptor) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			policy, err := interceptor.LoadPolicy(policyFile)
+			if err != nil {
+				log.Print("cannot reload access policy: ", err)
+				continue
+			}
+
+			authInterceptor.SetPolicy(policy)
+			log.Printf("reloaded access policy from %s", policyFile)
+		}
+	}()
+}
+
+func newLaptopStore(storeType string, db *bolt.DB) (repository.LaptopStore, error) {
+	switch storeType {
+	case "memory":
+		return repository.NewInMemoryLaptopStore(), nil
+	case "bolt":
+		return repository.NewBoltLaptopStore(db)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", storeType)
 	}
+}
 
-	return createUser(userStore, "user1", "secret", "user")
+func newRatingStore(storeType string, db *bolt.DB) (repository.RatingStore, error) {
+	switch storeType {
+	case "memory":
+		return repository.NewInMemoryRatingStore(), nil
+	case "bolt":
+		return repository.NewBoltRatingStore(db)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", storeType)
+	}
 }
 
-func accessibleRoles() map[string][]string {
-	return map[string][]string{
-		pb.LaptopService_CreateLaptop_FullMethodName: {"admin"},
-		pb.LaptopService_UploadImage_FullMethodName:  {"admin"},
-		pb.LaptopService_RateLaptop_FullMethodName:   {"admin", "user"},
+func newReviewStore(storeType string, db *bolt.DB) (repository.ReviewStore, error) {
+	switch storeType {
+	case "memory":
+		return repository.NewInMemoryReviewStore(), nil
+	case "bolt":
+		return repository.NewBoltReviewStore(db)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", storeType)
 	}
 }
 
-func loatTLSCredentials() (credentials.TransportCredentials, error) {
+func newUserStore(storeType string, db *bolt.DB) (repository.UserStore, error) {
+	switch storeType {
+	case "memory":
+		return repository.NewInMemoryUserStore(), nil
+	case "bolt":
+		return repository.NewBoltUserStore(db)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", storeType)
+	}
+}
+
+func newRevocationStore(storeType string, db *bolt.DB) (repository.RevocationStore, error) {
+	switch storeType {
+	case "memory":
+		return repository.NewInMemoryRevocationStore(), nil
+	case "bolt":
+		return repository.NewBoltRevocationStore(db)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", storeType)
+	}
+}
+
+func newImageStore(storeType string, s3Config repository.S3Config) (repository.ImageStore, error) {
+	switch storeType {
+	case "disk":
+		imageStore, err := repository.NewDiskImageStore("tmp/")
+		if err != nil {
+			return nil, err
+		}
+
+		report, err := imageStore.Reconcile()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range report.OrphanFiles {
+			log.Printf("image store: orphan file %s", path)
+		}
+		for _, id := range report.MissingBlobs {
+			log.Printf("image store: missing file of image %s", id)
+		}
+
+		return imageStore, nil
+	case "s3":
+		return repository.NewS3ImageStore(s3Config)
+	default:
+		return nil, fmt.Errorf("unknown image store type: %s", storeType)
+	}
+}
+
+// healthCheckers returns the stores that can become unavailable.
+func healthCheckers(stores ...interface{}) []repository.HealthChecker {
+	checkers := []repository.HealthChecker{}
+	for _, store := range stores {
+		if checker, ok := store.(repository.HealthChecker); ok {
+			checkers = append(checkers, checker)
+		}
+	}
+
+	return checkers
+}
+
+func jwtKeyOptions(signingKeyFile string, verificationKeyFiles string) ([]service.JWTManagerOption, error) {
+	opts := []service.JWTManagerOption{}
+
+	if signingKeyFile != "" {
+		key, err := service.LoadSigningKey(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("sign tokens with %s key %s", key.Method.Alg(), key.ID)
+		opts = append(opts, service.WithSigningKey(key))
+	}
+
+	if verificationKeyFiles != "" {
+		for _, file := range strings.Split(verificationKeyFiles, ",") {
+			key, err := service.LoadVerificationKey(file)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, service.WithVerificationKeys(key))
+		}
+	}
+
+	return opts, nil
+}
+
+// parseImageVariants parses a comma separated list of name=size variants,
+// such as "thumbnail=128,medium=512".
+func parseImageVariants(value string) ([]service.ImageVariant, error) {
+	variants := []service.ImageVariant{}
+	if value == "" {
+		return variants, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		name, size, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid image variant %q, want name=size", field)
+		}
+
+		pixels, err := strconv.Atoi(size)
+		if err != nil || pixels <= 0 {
+			return nil, fmt.Errorf("invalid size of image variant %q", field)
+		}
+
+		for _, r := range name {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				return nil, fmt.Errorf("invalid name of image variant %q", field)
+			}
+		}
+
+		variants = append(variants, service.ImageVariant{Name: name, Size: pixels})
+	}
+
+	return variants, nil
+}
+
+func loadTLSConfig(clientAuth tls.ClientAuthType) (*tls.Config, error) {
 	certPool, err := util.LoadCAPool()
 	if err != nil {
 		return nil, err
@@ -68,25 +262,53 @@ func loatTLSCredentials() (credentials.TransportCredentials, error) {
 
 	config := &tls.Config{
 		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientAuth:   clientAuth,
 		ClientCAs:    certPool,
 	}
 
+	return config, nil
+}
+
+func loatTLSCredentials() (credentials.TransportCredentials, error) {
+	config, err := loadTLSConfig(tls.RequireAndVerifyClientCert)
+	if err != nil {
+		return nil, err
+	}
+
 	return credentials.NewTLS(config), nil
 }
 
-func runGRPCServer(
-	laptopServer *service.LaptopServer,
-	authServer *service.AuthServer,
-	jwtManager *service.JWTManager,
-	listener net.Listener,
-	enableTLS bool,
-) error {
-	authInteceptor := interceptor.NewAuthInterceptor(jwtManager, accessibleRoles())
-	serverOptions := []grpc.ServerOption{
+// services are shared by the gRPC and REST servers, so both serve the same
+// data under the same access policy.
+type services struct {
+	laptopServer    *service.LaptopServer
+	authServer      *service.AuthServer
+	reviewServer    *service.ReviewServer
+	jwtManager      *service.JWTManager
+	authInterceptor *interceptor.AuthInterceptor
+	healthChecker   *service.HealthChecker
+}
+
+func authServerOptions(authInteceptor *interceptor.AuthInterceptor) []grpc.ServerOption {
+	return []grpc.ServerOption{
 		grpc.UnaryInterceptor(authInteceptor.Unary()),
 		grpc.StreamInterceptor(authInteceptor.Stream()),
 	}
+}
+
+func newGRPCServer(services *services, serverOptions ...grpc.ServerOption) *grpc.Server {
+	grpcServer := grpc.NewServer(append(authServerOptions(services.authInterceptor), serverOptions...)...)
+	pb.RegisterLaptopServiceServer(grpcServer, services.laptopServer)
+	pb.RegisterAuthServiceServer(grpcServer, services.authServer)
+	pb.RegisterReviewServiceServer(grpcServer, services.reviewServer)
+	healthpb.RegisterHealthServer(grpcServer, services.healthChecker)
+	reflection.Register(grpcServer)
+
+	return grpcServer
+}
+
+func runGRPCServer(ctx context.Context, services *services, listener net.Listener, enableTLS bool, shutdownTimeout time.Duration) error {
+	serverOptions := []grpc.ServerOption{}
 
 	if enableTLS {
 		tlsCredentials, err := loatTLSCredentials()
@@ -96,63 +318,445 @@ func runGRPCServer(
 		serverOptions = append(serverOptions, grpc.Creds(tlsCredentials))
 	}
 
-	grpcServer := grpc.NewServer(serverOptions...)
-	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
-	pb.RegisterAuthServiceServer(grpcServer, authServer)
-	reflection.Register(grpcServer)
+	grpcServer := newGRPCServer(services, serverOptions...)
 
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		stopGRPCServer(grpcServer, shutdownTimeout)
+		close(stopped)
+	}()
+
+	log.Printf("start gRPC server")
 	err := grpcServer.Serve(listener)
 	if err != nil {
 		return fmt.Errorf("cannot start server: %w", err)
 	}
 
+	<-stopped
 	return nil
 }
 
-func runRESTServer(
-	laptopServer *service.LaptopServer,
-	authServer *service.AuthServer,
-	jwtManager *service.JWTManager,
-	listener net.Listener,
-	enableTLS bool,
-) error {
-	mux := runtime.NewServeMux()
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// stopGRPCServer waits for the RPCs in progress, and cancels the ones still
+// running after shutdownTimeout.
+func stopGRPCServer(grpcServer *grpc.Server, shutdownTimeout time.Duration) {
+	timer := time.AfterFunc(shutdownTimeout, grpcServer.Stop)
+	defer timer.Stop()
+
+	grpcServer.GracefulStop()
+}
+
+// restMarshaler ends every JSON message with the stream delimiter itself and
+// reports no delimiter, so that streamed HttpBody chunks are written verbatim.
+type restMarshaler struct {
+	runtime.HTTPBodyMarshaler
+}
+
+func newRESTMarshaler() *restMarshaler {
+	return &restMarshaler{runtime.HTTPBodyMarshaler{
+		Marshaler: &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		},
+	}}
+}
+
+func (marshaler *restMarshaler) Marshal(v interface{}) ([]byte, error) {
+	data, err := marshaler.HTTPBodyMarshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := v.(*httpbody.HttpBody); ok {
+		return data, nil
+	}
+
+	return append(data, '\n'), nil
+}
+
+func (marshaler *restMarshaler) Delimiter() []byte {
+	return nil
+}
+
+// dialInProcess serves grpcServer on an in-memory listener, through which the
+// REST gateway calls it instead of calling the services directly, so
+// streaming RPCs work and the interceptors still apply.
+func dialInProcess(grpcServer *grpc.Server) (*grpc.ClientConn, error) {
+	bufListener := bufconn.Listen(inProcessBufferSize)
+	go grpcServer.Serve(bufListener)
+
+	conn, err := grpc.DialContext(
+		context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return bufListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial in-process server: %w", err)
+	}
+
+	return conn, nil
+}
+
+func newRESTHandler(services *services, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, newRESTMarshaler()))
+	ctx := context.Background()
+
+	err := pb.RegisterAuthServiceHandler(ctx, mux, conn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot register auth server: %w", err)
+	}
+
+	err = pb.RegisterLaptopServiceHandler(ctx, mux, conn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot register laptop server: %w", err)
+	}
+
+	err = pb.RegisterReviewServiceHandler(ctx, mux, conn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot register review server: %w", err)
+	}
+
+	err = mux.HandlePath(http.MethodGet, "/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(services.jwtManager.JWKS())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot register jwks handler: %w", err)
+	}
 
-	err := pb.RegisterAuthServiceHandlerServer(ctx, mux, authServer)
+	// /healthz tells that the server is alive, so it always succeeds, while
+	// /readyz fails when a store is unavailable or the server is draining
+	err = mux.HandlePath(http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		writeHealth(w, services.healthChecker, false)
+	})
 	if err != nil {
-		return fmt.Errorf("cannot register auth server: %w", err)
+		return nil, fmt.Errorf("cannot register healthz handler: %w", err)
 	}
 
-	err = pb.RegisterLaptopServiceHandlerServer(ctx, mux, laptopServer)
+	err = mux.HandlePath(http.MethodGet, "/readyz", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		writeHealth(w, services.healthChecker, true)
+	})
 	if err != nil {
-		return fmt.Errorf("cannot register laptop server: %w", err)
+		return nil, fmt.Errorf("cannot register readyz handler: %w", err)
+	}
+
+	return mux, nil
+}
+
+// writeHealth writes the serving status of the server and of every service,
+// failing with 503 when ready is required and the server is not serving.
+func writeHealth(w http.ResponseWriter, healthChecker *service.HealthChecker, ready bool) {
+	res := struct {
+		Status   string            `json:"status"`
+		Services map[string]string `json:"services"`
+	}{
+		Services: make(map[string]string),
+	}
+
+	for name, status := range healthChecker.Statuses() {
+		if name == "" {
+			res.Status = status.String()
+			continue
+		}
+		res.Services[name] = status.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if ready && res.Status != healthpb.HealthCheckResponse_SERVING.String() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func runRESTServer(ctx context.Context, services *services, listener net.Listener, enableTLS bool, shutdownTimeout time.Duration) error {
+	grpcServer := newGRPCServer(services)
+	defer grpcServer.Stop()
+
+	conn, err := dialInProcess(grpcServer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	handler, err := newRESTHandler(services, conn)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if enableTLS {
+		tlsConfig, err = loadTLSConfig(tls.NoClientCert)
+		if err != nil {
+			return fmt.Errorf("cannot load TLS config: %w", err)
+		}
 	}
 
 	log.Printf("start REST server")
+	return serveHTTP(ctx, handler, listener, tlsConfig, shutdownTimeout)
+}
+
+// runServer serves gRPC and REST on the same listener. With TLS, REST clients
+// need no certificate, like on the REST server, while gRPC clients still do.
+func runServer(ctx context.Context, services *services, listener net.Listener, enableTLS bool, shutdownTimeout time.Duration) error {
+	var tlsConfig *tls.Config
 	if enableTLS {
-		return http.ServeTLS(listener, mux, serverCertFile, serverKeyFile)
+		var err error
+		tlsConfig, err = loadTLSConfig(tls.VerifyClientCertIfGiven)
+		if err != nil {
+			return fmt.Errorf("cannot load TLS config: %w", err)
+		}
+	}
+
+	log.Printf("start gRPC and REST server")
+	return serveGRPCAndREST(ctx, services, listener, tlsConfig, shutdownTimeout)
+}
+
+// serveGRPCAndREST tells gRPC requests apart by their HTTP/2 content type,
+// and sends them to the same gRPC server as the REST gateway does. With TLS,
+// gRPC requests without a verified client certificate are rejected.
+func serveGRPCAndREST(ctx context.Context, services *services, listener net.Listener, tlsConfig *tls.Config, shutdownTimeout time.Duration) error {
+	grpcServer := newGRPCServer(services)
+	defer grpcServer.Stop()
+
+	conn, err := dialInProcess(grpcServer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	restHandler, err := newRESTHandler(services, conn)
+	if err != nil {
+		return err
 	}
 
-	return http.Serve(listener, mux)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			if tlsConfig != nil && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+				writeGRPCError(w, status.New(codes.Unauthenticated, "client certificate is required"))
+				return
+			}
+
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+
+		restHandler.ServeHTTP(w, r)
+	})
+
+	return serveHTTP(ctx, handler, listener, tlsConfig, shutdownTimeout)
+}
+
+// writeGRPCError answers a gRPC request with only the status, as a
+// trailers-only response.
+func writeGRPCError(w http.ResponseWriter, st *status.Status) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(st.Code())))
+	w.Header().Set("Grpc-Message", st.Message())
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveHTTP serves HTTP/1.1 and HTTP/2, with or without TLS, until ctx is
+// done. It then waits for the requests in progress, and cancels the ones still
+// running after shutdownTimeout.
+func serveHTTP(ctx context.Context, handler http.Handler, listener net.Listener, tlsConfig *tls.Config, shutdownTimeout time.Duration) error {
+	// HTTP/2 connections without TLS are hijacked from the HTTP server, which
+	// doesn't wait for their requests when shutting down, so they are counted
+	var requests atomic.Int64
+	http2Server := &http2.Server{}
+	httpServer := &http.Server{
+		Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			defer requests.Add(-1)
+			handler.ServeHTTP(w, r)
+		}), http2Server),
+		TLSConfig: tlsConfig,
+	}
+
+	err := http2.ConfigureServer(httpServer, http2Server)
+	if err != nil {
+		return fmt.Errorf("cannot configure HTTP/2: %w", err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := httpServer.Shutdown(shutdownCtx)
+		for err == nil && requests.Load() > 0 {
+			select {
+			case <-shutdownCtx.Done():
+				err = shutdownCtx.Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		if err != nil {
+			httpServer.Close()
+			err = fmt.Errorf("cannot finish requests in progress: %w", err)
+		}
+		stopped <- err
+	}()
+
+	if tlsConfig != nil {
+		err = httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = httpServer.Serve(listener)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("cannot start server: %w", err)
+	}
+
+	return <-stopped
 }
 
 func main() {
 	port := flag.Int("port", 0, "the server port")
 	enableTLS := flag.Bool("tls", false, "enable SSL/TLS")
-	serverType := flag.String("type", "grpc", "type of server (grpc/rest)")
+	serverType := flag.String("type", "grpc", "type of server (grpc/rest/both), both serving gRPC and REST on the same port")
+	storeType := flag.String("store", "memory", "type of the laptop, rating, review, user and token revocation stores (memory/bolt)")
+	dataDir := flag.String("data-dir", "data", "directory of the persistent stores")
+	maxImageSize := flag.Int64("max-image-size", service.DEFAULT_MAX_IMAGE_SIZE, "maximum size of an uploaded image in bytes, below 4 GiB")
+	uploadTTL := flag.Duration("upload-ttl", repository.DEFAULT_UPLOAD_TTL, "how long an unfinished image upload is kept after its last chunk")
+	imageVariants := flag.String("image-variants", "thumbnail=128,medium=512", "resized variants of uploaded images, as name=size pairs")
+	jwtSigningKey := flag.String("jwt-signing-key", "", "PEM file of the RSA, ECDSA or Ed25519 key that signs tokens, instead of the HS256 secret read from JWT_SECRET")
+	jwtVerificationKeys := flag.String("jwt-verification-keys", "", "comma separated PEM files of other keys whose tokens are accepted, such as the previous signing key")
+	jwtIssuer := flag.String("jwt-issuer", service.DEFAULT_TOKEN_ISSUER, "iss claim of issued tokens, required in verified tokens")
+	jwtAudience := flag.String("jwt-audience", service.DEFAULT_TOKEN_AUDIENCE, "aud claim of issued tokens, required in verified tokens")
+	jwtLeeway := flag.Duration("jwt-leeway", service.DEFAULT_TOKEN_LEEWAY, "allowed clock skew when checking the exp, nbf and iat claims of tokens")
+	refreshTokenDuration := flag.Duration("refresh-token-duration", service.DEFAULT_REFRESH_TOKEN_DURATION, "how long a refresh token can be used")
+	maxUserLoginFailures := flag.Int("max-user-login-failures", service.DEFAULT_MAX_USER_LOGIN_FAILURES, "failed logins of a username before it is locked out, 0 for no limit")
+	maxIPLoginFailures := flag.Int("max-ip-login-failures", service.DEFAULT_MAX_IP_LOGIN_FAILURES, "failed logins from an IP before it is locked out, 0 for no limit")
+	loginLockout := flag.Duration("login-lockout", service.DEFAULT_LOGIN_LOCKOUT, "first lockout after too many failed logins, doubled after each further failure")
+	maxLoginLockout := flag.Duration("max-login-lockout", service.DEFAULT_MAX_LOGIN_LOCKOUT, "longest lockout after failed logins")
+	loginFailureWindow := flag.Duration("login-failure-window", service.DEFAULT_LOGIN_FAILURE_WINDOW, "how long failed logins are remembered after the last one")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "how long to wait for the requests in progress when shutting down, before cancelling them")
+	drainDelay := flag.Duration("drain-delay", 0, "how long to report not serving when shutting down before refusing new requests, so load balancers stop sending them")
+	healthCheckInterval := flag.Duration("health-check-interval", service.DEFAULT_HEALTH_CHECK_INTERVAL, "how often to check that the stores are available")
+	adminUsername := flag.String("admin-username", "admin1", "username of the admin created at startup with the password read from ADMIN_PASSWORD")
+	policyFile := flag.String("policy", "config/rbac.yaml", "YAML or JSON file of the roles allowed to call each method, reloaded on SIGHUP")
+	minScore := flag.Float64("min-score", service.DEFAULT_MIN_SCORE, "lowest score accepted when rating a laptop")
+	maxScore := flag.Float64("max-score", service.DEFAULT_MAX_SCORE, "highest score accepted when rating a laptop")
+	imageStoreType := flag.String("image-store", "disk", "type of image store (disk/s3)")
+	s3Config := repository.S3Config{}
+	flag.StringVar(&s3Config.Endpoint, "s3-endpoint", "localhost:9000", "host:port of the S3-compatible image storage")
+	flag.StringVar(&s3Config.Region, "s3-region", "us-east-1", "region of the S3 bucket")
+	flag.StringVar(&s3Config.Bucket, "s3-bucket", "laptop-images", "S3 bucket of the images")
+	flag.BoolVar(&s3Config.Secure, "s3-secure", false, "connect to the S3 storage over TLS")
+	s3Config.AccessKey = os.Getenv("S3_ACCESS_KEY")
+	s3Config.SecretKey = os.Getenv("S3_SECRET_KEY")
 	flag.Parse()
 	log.Printf("start server on port: %d, TLS: %t", *port, *enableTLS)
 
-	laptopStore := repository.NewInMemoryLaptopStore()
-	imageStore := repository.NewDiskImageStore("tmp/")
-	ratingStore := repository.NewInMemoryRatingStore()
-	userStore := repository.NewInMemoryUserStore()
-	jwtManager := service.NewJWTManager(secretKey, tokenDuration)
+	var db *bolt.DB
+	if *storeType == "bolt" {
+		var err error
+		db, err = repository.OpenBoltDB(*dataDir)
+		if err != nil {
+			log.Fatal("cannot open database: ", err)
+		}
+		defer db.Close()
+	}
+
+	laptopStore, err := newLaptopStore(*storeType, db)
+	if err != nil {
+		log.Fatal("cannot create laptop store: ", err)
+	}
+	imageStore, err := newImageStore(*imageStoreType, s3Config)
+	if err != nil {
+		log.Fatal("cannot open image store: ", err)
+	}
+	ratingStore, err := newRatingStore(*storeType, db)
+	if err != nil {
+		log.Fatal("cannot create rating store: ", err)
+	}
+	reviewStore, err := newReviewStore(*storeType, db)
+	if err != nil {
+		log.Fatal("cannot create review store: ", err)
+	}
+	userStore, err := newUserStore(*storeType, db)
+	if err != nil {
+		log.Fatal("cannot create user store: ", err)
+	}
+	revocationStore, err := newRevocationStore(*storeType, db)
+	if err != nil {
+		log.Fatal("cannot create revocation store: ", err)
+	}
+	keyOptions, err := jwtKeyOptions(*jwtSigningKey, *jwtVerificationKeys)
+	if err != nil {
+		log.Fatal("cannot load JWT keys: ", err)
+	}
+
+	// tokens are signed with the key file, or else with the HS256 secret,
+	// which is never compiled in
+	jwtSecretKey := os.Getenv("JWT_SECRET")
+	if *jwtSigningKey != "" {
+		jwtSecretKey = ""
+	} else if jwtSecretKey == "" {
+		log.Fatal("cannot sign tokens: set -jwt-signing-key or the JWT_SECRET environment variable")
+	}
+
+	jwtManager := service.NewJWTManager(jwtSecretKey, tokenDuration, append(keyOptions,
+		service.WithRefreshTokenDuration(*refreshTokenDuration),
+		service.WithRevocationStore(revocationStore),
+		service.WithIssuer(*jwtIssuer),
+		service.WithAudience(*jwtAudience),
+		service.WithLeeway(*jwtLeeway),
+	)...)
+
+	// the size of an uploaded image is reported as a uint32
+	if *maxImageSize <= 0 || *maxImageSize > math.MaxUint32 {
+		log.Fatalf("max image size %d is not between 1 and %d bytes", *maxImageSize, uint32(math.MaxUint32))
+	}
+
+	// the histogram buckets of the scores are reported as int32, one for every
+	// whole score of the range
+	if !(*minScore >= math.MinInt32 && *maxScore <= math.MaxInt32) {
+		log.Fatalf("score range [%v, %v] is not within [%d, %d]", *minScore, *maxScore, math.MinInt32, math.MaxInt32)
+	}
+
+	if *minScore > *maxScore {
+		log.Fatalf("min score %v is greater than max score %v", *minScore, *maxScore)
+	}
+
+	if math.Floor(*maxScore)-math.Floor(*minScore) >= service.MAX_SCORE_BUCKETS {
+		log.Fatalf("score range [%v, %v] has more than %d histogram buckets", *minScore, *maxScore, service.MAX_SCORE_BUCKETS)
+	}
+
+	variants, err := parseImageVariants(*imageVariants)
+	if err != nil {
+		log.Fatal("cannot parse image variants: ", err)
+	}
+
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore,
+		service.WithMaxImageSize(*maxImageSize),
+		service.WithUploadStore(repository.NewDiskUploadStore(service.UploadFolder(imageStore), repository.WithUploadTTL(*uploadTTL))),
+		service.WithImageVariants(variants...),
+		service.WithScoreRange(*minScore, *maxScore),
+		service.WithReviewStore(reviewStore),
+	)
+	loginLimiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(*maxUserLoginFailures, *maxIPLoginFailures),
+		service.WithLoginLockout(*loginLockout, *maxLoginLockout),
+		service.WithLoginFailureWindow(*loginFailureWindow),
+	)
+	authServer := service.NewAuthServer(userStore, jwtManager, service.WithLoginLimiter(loginLimiter))
+
+	policy, err := interceptor.LoadPolicy(*policyFile)
+	if err != nil {
+		log.Fatal("cannot load access policy: ", err)
+	}
+	authInterceptor := interceptor.NewAuthInterceptor(jwtManager, policy)
+	reloadPolicyOnSignal(*policyFile, authInterceptor)
 
-	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore)
-	authServer := service.NewAuthServer(userStore, jwtManager)
+	reviewServer := service.NewReviewServer(reviewStore, laptopStore, service.WithPermissionChecker(authInterceptor.HasPermission))
 
 	address := fmt.Sprintf("0.0.0.0:%d", *port)
 	listener, err := net.Listen("tcp", address)
@@ -160,17 +764,59 @@ func main() {
 		log.Fatal("cannot start server: ", err)
 	}
 
-	err = seedUsers(userStore)
+	err = seedAdmin(userStore, *adminUsername, os.Getenv("ADMIN_PASSWORD"))
 	if err != nil {
-		log.Fatal("cannot seed users: %w", err)
+		log.Fatal("cannot create admin: ", err)
 	}
 
-	if *serverType == "grpc" {
-		err = runGRPCServer(laptopServer, authServer, jwtManager, listener, *enableTLS)
-	} else {
-		err = runRESTServer(laptopServer, authServer, jwtManager, listener, *enableTLS)
+	healthChecker := service.NewHealthChecker(map[string][]repository.HealthChecker{
+		pb.LaptopService_ServiceDesc.ServiceName: healthCheckers(laptopStore, imageStore, ratingStore),
+		pb.AuthService_ServiceDesc.ServiceName:   healthCheckers(userStore, revocationStore),
+		pb.ReviewService_ServiceDesc.ServiceName: healthCheckers(reviewStore, laptopStore),
+	})
+	healthChecker.Update(context.Background())
+
+	services := &services{
+		laptopServer:    laptopServer,
+		authServer:      authServer,
+		reviewServer:    reviewServer,
+		jwtManager:      jwtManager,
+		authInterceptor: authInterceptor,
+		healthChecker:   healthChecker,
+	}
+
+	// the first signal reports the server as not serving, and shuts it down
+	// gracefully after the drain delay, while a second one kills it right away
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go healthChecker.Run(ctx, *healthCheckInterval)
+	go func() {
+		<-signalCtx.Done()
+		stop()
+		log.Printf("drain server")
+		healthChecker.Shutdown()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(*drainDelay):
+		}
+		log.Printf("shut down server")
+		cancel()
+	}()
+
+	switch *serverType {
+	case "grpc":
+		err = runGRPCServer(ctx, services, listener, *enableTLS, *shutdownTimeout)
+	case "rest":
+		err = runRESTServer(ctx, services, listener, *enableTLS, *shutdownTimeout)
+	case "both":
+		err = runServer(ctx, services, listener, *enableTLS, *shutdownTimeout)
+	default:
+		err = fmt.Errorf("unknown server type: %s", *serverType)
 	}
 	if err != nil {
-		log.Fatal("cannot start server: ", err)
+		log.Fatal("cannot run server: ", err)
 	}
 }
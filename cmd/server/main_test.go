@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/sample"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service/interceptor"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRESTAndGRPCAuthorization(t *testing.T) {
+	t.Parallel()
+
+	grpcAddress, restAddress, jwtManager := startTestServers(t)
+	conn, err := grpc.Dial(grpcAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	userToken, err := jwtManager.Generate(&entity.User{Username: "alice", Role: entity.ROLE_USER})
+	require.NoError(t, err)
+	adminToken, err := jwtManager.Generate(&entity.User{Username: "bob", Role: entity.ROLE_ADMIN})
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+
+	testCases := []struct {
+		name       string
+		method     string
+		req        proto.Message
+		res        proto.Message
+		httpMethod string
+		path       string
+		anonymous  codes.Code
+		user       codes.Code
+		admin      codes.Code
+	}{
+		{
+			name:       "create laptop",
+			method:     pb.LaptopService_CreateLaptop_FullMethodName,
+			req:        &pb.CreateLaptopRequest{Laptop: laptop},
+			res:        &pb.CreateLaptopResponse{},
+			httpMethod: http.MethodPost,
+			path:       "/v1/laptop/create",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.OK,
+		},
+		{
+			name:       "delete laptop",
+			method:     pb.LaptopService_DeleteLaptop_FullMethodName,
+			req:        &pb.DeleteLaptopRequest{Id: "missing"},
+			res:        &pb.DeleteLaptopResponse{},
+			httpMethod: http.MethodDelete,
+			path:       "/v1/laptop/delete/missing",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.NotFound,
+		},
+		{
+			name:       "get laptop",
+			method:     pb.LaptopService_GetLaptop_FullMethodName,
+			req:        &pb.GetLaptopRequest{Id: "missing"},
+			res:        &pb.GetLaptopResponse{},
+			httpMethod: http.MethodGet,
+			path:       "/v1/laptop/get/missing",
+			anonymous:  codes.NotFound,
+			user:       codes.NotFound,
+			admin:      codes.NotFound,
+		},
+		{
+			name:       "moderate review",
+			method:     pb.ReviewService_ModerateReview_FullMethodName,
+			req:        &pb.ModerateReviewRequest{Id: "missing", Hidden: true},
+			res:        &pb.ModerateReviewResponse{},
+			httpMethod: http.MethodPost,
+			path:       "/v1/review/moderate",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.NotFound,
+		},
+		{
+			name:       "list users",
+			method:     pb.AuthService_ListUsers_FullMethodName,
+			req:        &pb.ListUsersRequest{},
+			res:        &pb.ListUsersResponse{},
+			httpMethod: http.MethodGet,
+			path:       "/v1/auth/users",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.OK,
+		},
+		{
+			name:       "change password",
+			method:     pb.AuthService_ChangePassword_FullMethodName,
+			req:        &pb.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "Laptop2023"},
+			res:        &pb.ChangePasswordResponse{},
+			httpMethod: http.MethodPost,
+			path:       "/v1/auth/change_password",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.PermissionDenied,
+		},
+		{
+			name:       "unlock user",
+			method:     pb.AuthService_UnlockUser_FullMethodName,
+			req:        &pb.UnlockUserRequest{Username: "missing"},
+			res:        &pb.UnlockUserResponse{},
+			httpMethod: http.MethodPost,
+			path:       "/v1/auth/unlock_user",
+			anonymous:  codes.Unauthenticated,
+			user:       codes.PermissionDenied,
+			admin:      codes.NotFound,
+		},
+	}
+
+	credentials := []struct {
+		name string
+		role string
+		grpc string
+		rest string
+	}{
+		{"anonymous", "", "", ""},
+		{"invalid token", "", "invalid", "Bearer invalid"},
+		{"basic auth", "", "Basic YWRtaW4xOnNlY3JldA==", "Basic YWRtaW4xOnNlY3JldA=="},
+		{"user", entity.ROLE_USER, userToken, "Bearer " + userToken},
+		{"user with scheme", entity.ROLE_USER, "Bearer " + userToken, "bearer " + userToken},
+		{"admin", entity.ROLE_ADMIN, adminToken, "Bearer " + adminToken},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		for j := range credentials {
+			credential := credentials[j]
+			t.Run(tc.name+"/"+credential.name, func(t *testing.T) {
+				t.Parallel()
+
+				code := tc.anonymous
+				switch credential.role {
+				case entity.ROLE_USER:
+					code = tc.user
+				case entity.ROLE_ADMIN:
+					code = tc.admin
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				grpcCtx := ctx
+				if credential.grpc != "" {
+					grpcCtx = metadata.AppendToOutgoingContext(ctx, "authorization", credential.grpc)
+				}
+				err := conn.Invoke(grpcCtx, tc.method, tc.req, proto.Clone(tc.res))
+				require.Equal(t, code, status.Code(err), "gRPC: %v", err)
+
+				var body []byte
+				if tc.httpMethod == http.MethodPost {
+					body, err = protojson.Marshal(tc.req)
+					require.NoError(t, err)
+				}
+
+				req, err := http.NewRequestWithContext(ctx, tc.httpMethod, "http://"+restAddress+tc.path, bytes.NewReader(body))
+				require.NoError(t, err)
+				if credential.rest != "" {
+					req.Header.Set("Authorization", credential.rest)
+				}
+
+				res, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				res.Body.Close()
+				require.Equal(t, runtime.HTTPStatusFromCode(code), res.StatusCode, "REST")
+			})
+		}
+	}
+}
+
+func TestServerBoth(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	services, jwtManager := newTestServices(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- runServer(ctx, services, listener, false, 5*time.Second)
+	}()
+
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	laptop := sample.NewLaptop()
+	adminToken, err := jwtManager.Generate(&entity.User{Username: "bob", Role: entity.ROLE_ADMIN})
+	require.NoError(t, err)
+
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", adminToken)
+	_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(adminCtx, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	// the laptop created over gRPC is served over REST on the same port
+	res, err := http.Get("http://" + address + "/v1/laptop/get/" + laptop.GetId())
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	getRes := &pb.GetLaptopResponse{}
+	err = protojson.Unmarshal(body, getRes)
+	require.NoError(t, err)
+	require.Equal(t, laptop.GetId(), getRes.GetLaptop().GetId())
+
+	cancel()
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "server did not stop")
+	}
+
+	_, err = net.Dial("tcp", address)
+	require.Error(t, err)
+}
+
+func TestServerHealth(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	imageFolder := t.TempDir()
+	imageStore, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	services, _ := newTestServices(t)
+	services.healthChecker = service.NewHealthChecker(map[string][]repository.HealthChecker{
+		pb.LaptopService_ServiceDesc.ServiceName: {imageStore},
+		pb.AuthService_ServiceDesc.ServiceName:   {},
+	})
+	services.healthChecker.Update(ctx)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	go runServer(ctx, services, listener, false, 5*time.Second)
+
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	healthClient := healthpb.NewHealthClient(conn)
+
+	requireHealth := func(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		res, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		require.Equal(t, status, res.GetStatus())
+	}
+
+	requireHTTPStatus := func(path string, code int) {
+		t.Helper()
+		res, err := http.Get("http://" + address + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, code, res.StatusCode)
+	}
+
+	requireHealth("", healthpb.HealthCheckResponse_SERVING)
+	requireHealth(pb.LaptopService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	requireHTTPStatus("/healthz", http.StatusOK)
+	requireHTTPStatus("/readyz", http.StatusOK)
+
+	// the laptop service can't store images anymore
+	require.NoError(t, os.RemoveAll(imageFolder))
+	services.healthChecker.Update(ctx)
+
+	requireHealth("", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireHealth(pb.LaptopService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	requireHealth(pb.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	requireHTTPStatus("/healthz", http.StatusOK)
+	requireHTTPStatus("/readyz", http.StatusServiceUnavailable)
+
+	res, err := http.Get("http://" + address + "/readyz")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	health := struct {
+		Status   string            `json:"status"`
+		Services map[string]string `json:"services"`
+	}{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&health))
+	require.Equal(t, "NOT_SERVING", health.Status)
+	require.Equal(t, map[string]string{
+		pb.LaptopService_ServiceDesc.ServiceName: "NOT_SERVING",
+		pb.AuthService_ServiceDesc.ServiceName:   "SERVING",
+	}, health.Services)
+
+	require.NoError(t, os.MkdirAll(imageFolder, 0o755))
+	services.healthChecker.Update(ctx)
+	requireHTTPStatus("/readyz", http.StatusOK)
+
+	// every service stops serving while the server drains
+	services.healthChecker.Shutdown()
+	requireHealth("", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireHealth(pb.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	requireHTTPStatus("/healthz", http.StatusOK)
+	requireHTTPStatus("/readyz", http.StatusServiceUnavailable)
+}
+
+// startTestServers runs a gRPC and a REST server with the same stores and
+// access policy.
+func startTestServers(t *testing.T) (string, string, *service.JWTManager) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	services, jwtManager := newTestServices(t)
+
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go runGRPCServer(ctx, services, grpcListener, false, 5*time.Second)
+
+	restListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go runRESTServer(ctx, services, restListener, false, 5*time.Second)
+
+	return grpcListener.Addr().String(), restListener.Addr().String(), jwtManager
+}
+
+func newTestServices(t *testing.T) (*services, *service.JWTManager) {
+	jwtManager := service.NewJWTManager("test-secret", time.Minute)
+
+	imageStore, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+	laptopStore := repository.NewInMemoryLaptopStore()
+
+	policy, err := interceptor.LoadPolicy("../../config/rbac.yaml")
+	require.NoError(t, err)
+
+	healthChecker := service.NewHealthChecker(map[string][]repository.HealthChecker{
+		pb.LaptopService_ServiceDesc.ServiceName: {imageStore},
+		pb.AuthService_ServiceDesc.ServiceName:   {},
+		pb.ReviewService_ServiceDesc.ServiceName: {},
+	})
+	healthChecker.Update(context.Background())
+
+	return &services{
+		laptopServer:    service.NewLaptopServer(laptopStore, imageStore, repository.NewInMemoryRatingStore()),
+		authServer:      service.NewAuthServer(repository.NewInMemoryUserStore(), jwtManager),
+		reviewServer:    service.NewReviewServer(repository.NewInMemoryReviewStore(), laptopStore),
+		jwtManager:      jwtManager,
+		authInterceptor: interceptor.NewAuthInterceptor(jwtManager, policy),
+		healthChecker:   healthChecker,
+	}, jwtManager
+}
+
+func TestSeedAdmin(t *testing.T) {
+	t.Parallel()
+
+	userStore := repository.NewInMemoryUserStore()
+
+	err := seedAdmin(userStore, "admin1", "")
+	require.NoError(t, err)
+	user, err := userStore.Find("admin1")
+	require.NoError(t, err)
+	require.Nil(t, user)
+
+	err = seedAdmin(userStore, "admin1", "secret")
+	require.Error(t, err)
+
+	err = seedAdmin(userStore, "admin1", "Laptop2023")
+	require.NoError(t, err)
+	user, err = userStore.Find("admin1")
+	require.NoError(t, err)
+	require.Equal(t, entity.ROLE_ADMIN, user.Role)
+	require.True(t, user.IsCorrectPassword("Laptop2023"))
+
+	// an existing admin keeps its password
+	err = seedAdmin(userStore, "admin1", "Laptop2024")
+	require.NoError(t, err)
+	user, err = userStore.Find("admin1")
+	require.NoError(t, err)
+	require.True(t, user.IsCorrectPassword("Laptop2023"))
+}
+
+func TestServerBothTLS(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	services, jwtManager := newTestServices(t)
+
+	caCert, caKey := newTestCertificate(t, nil, nil)
+	serverCert, _ := newTestCertificate(t, &caCert, caKey)
+	clientCert, _ := newTestCertificate(t, &caCert, caKey)
+	certPool := x509.NewCertPool()
+	certPool.AddCert(caCert.Leaf)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    certPool,
+	}
+	go serveGRPCAndREST(ctx, services, listener, tlsConfig, 5*time.Second)
+
+	// REST clients need no certificate, like on the REST server
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certPool}}}
+	require.Eventually(t, func() bool {
+		res, err := httpClient.Get("https://" + address + "/healthz")
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	adminToken, err := jwtManager.Generate(&entity.User{Username: "bob", Role: entity.ROLE_ADMIN})
+	require.NoError(t, err)
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", adminToken)
+
+	testCases := []struct {
+		name         string
+		certificates []tls.Certificate
+		code         codes.Code
+	}{
+		{"client certificate", []tls.Certificate{clientCert}, codes.OK},
+		{"no client certificate", nil, codes.Unauthenticated},
+	}
+
+	for _, tc := range testCases {
+		creds := credentials.NewTLS(&tls.Config{Certificates: tc.certificates, RootCAs: certPool})
+		conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = pb.NewLaptopServiceClient(conn).CreateLaptop(adminCtx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		require.Equal(t, tc.code, status.Code(err), tc.name)
+	}
+}
+
+// newTestCertificate returns a self-signed CA certificate when parent is nil,
+// and otherwise a certificate of 127.0.0.1 for servers and clients signed by
+// parent.
+func newTestCertificate(t *testing.T, parent *tls.Certificate, parentKey *ecdsa.PrivateKey) (tls.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	parentTemplate, signer := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		parentTemplate, signer = parent.Leaf, parentKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentTemplate, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, key
+}
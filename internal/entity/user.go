@@ -1,15 +1,32 @@
 package entity
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	ROLE_ADMIN = "admin"
+	ROLE_USER  = "user"
+
+	MIN_USERNAME_LENGTH = 3
+	MAX_USERNAME_LENGTH = 32
+	MIN_PASSWORD_LENGTH = 8
+	// bcrypt ignores everything after the first 72 bytes
+	MAX_PASSWORD_LENGTH = 72
+)
+
 type User struct {
 	Username       string
 	HashedPassword []byte
 	Role           string
+	// TokenEpoch changes with the password, which ends the sessions of the
+	// tokens issued before
+	TokenEpoch int
 }
 
 func NewUser(username string, password string, role string) (*User, error) {
@@ -25,6 +42,19 @@ func NewUser(username string, password string, role string) (*User, error) {
 	}, nil
 }
 
+// SetPassword replaces the hashed password of the user, and moves on to the
+// next token epoch.
+func (u *User) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("cannot hash password: %w", err)
+	}
+
+	u.HashedPassword = hashedPassword
+	u.TokenEpoch++
+	return nil
+}
+
 func (u *User) IsCorrectPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password))
 	return err == nil
@@ -35,5 +65,56 @@ func (u *User) Clone() *User {
 		Username:       u.Username,
 		HashedPassword: u.HashedPassword,
 		Role:           u.Role,
+		TokenEpoch:     u.TokenEpoch,
+	}
+}
+
+func IsValidRole(role string) bool {
+	return role == ROLE_ADMIN || role == ROLE_USER
+}
+
+// ValidateUsername accepts letters, digits, dots, dashes and underscores.
+func ValidateUsername(username string) error {
+	if len(username) < MIN_USERNAME_LENGTH || len(username) > MAX_USERNAME_LENGTH {
+		return fmt.Errorf("username must have between %d and %d characters", MIN_USERNAME_LENGTH, MAX_USERNAME_LENGTH)
 	}
+
+	for _, r := range username {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("._-", r)) {
+			return errors.New("username may only contain letters, digits, dots, dashes and underscores")
+		}
+	}
+
+	return nil
+}
+
+// ValidatePassword enforces the password policy: between 8 and 72 bytes,
+// with at least one lowercase letter, one uppercase letter and one digit,
+// and not containing the username.
+func ValidatePassword(username string, password string) error {
+	if len(password) < MIN_PASSWORD_LENGTH || len(password) > MAX_PASSWORD_LENGTH {
+		return fmt.Errorf("password must have between %d and %d bytes", MIN_PASSWORD_LENGTH, MAX_PASSWORD_LENGTH)
+	}
+
+	var lower, upper, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+
+	if !lower || !upper || !digit {
+		return errors.New("password must contain a lowercase letter, an uppercase letter and a digit")
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+
+	return nil
 }
@@ -0,0 +1,50 @@
+package entity_test
+
+import (
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePassword(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"valid", "Laptop2023", true},
+		{"unicode letters", "Ñandú2023x", true},
+		{"too short", "Lap2023", false},
+		{"too long", "Laptop2023" + string(make([]byte, 70)), false},
+		{"no lowercase", "LAPTOP2023", false},
+		{"no uppercase", "laptop2023", false},
+		{"no digit", "LaptopLaptop", false},
+		{"contains username", "xAlice2023x", false},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := entity.ValidatePassword("alice", tc.password)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, entity.ValidateUsername("john.doe-99_x"))
+	require.Error(t, entity.ValidateUsername("jo"))
+	require.Error(t, entity.ValidateUsername("john doe"))
+	require.Error(t, entity.ValidateUsername("jöhn"))
+}
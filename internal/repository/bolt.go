@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFileName = "store.db"
+
+func OpenBoltDB(dataDir string) (*bolt.DB, error) {
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dataDir, boltFileName), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open bolt database: %w", err)
+	}
+
+	return db, nil
+}
+
+func createBucket(db *bolt.DB, name []byte) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return fmt.Errorf("cannot create bucket %s: %w", name, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+var laptopBucket = []byte("laptops")
+
+type BoltLaptopStore struct {
+	db *bolt.DB
+}
+
+func NewBoltLaptopStore(db *bolt.DB) (*BoltLaptopStore, error) {
+	err := createBucket(db, laptopBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltLaptopStore{db: db}, nil
+}
+
+func (store *BoltLaptopStore) Save(laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(laptopBucket)
+		if bucket.Get([]byte(laptop.Id)) != nil {
+			return ErrAlreadyExists
+		}
+
+		return bucket.Put([]byte(laptop.Id), data)
+	})
+}
+
+func (store *BoltLaptopStore) Find(id string) (*pb.Laptop, error) {
+	var laptop *pb.Laptop
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(laptopBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+
+		var err error
+		laptop, err = unmarshalLaptop(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return laptop, nil
+}
+
+func (store *BoltLaptopStore) Update(laptop *pb.Laptop) error {
+	data, err := proto.Marshal(laptop)
+	if err != nil {
+		return fmt.Errorf("cannot marshal laptop: %w", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(laptopBucket)
+		if bucket.Get([]byte(laptop.Id)) == nil {
+			return ErrNotFound
+		}
+
+		return bucket.Put([]byte(laptop.Id), data)
+	})
+}
+
+func (store *BoltLaptopStore) Delete(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(laptopBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func (store *BoltLaptopStore) List(ctx context.Context, afterID string, limit int) ([]*pb.Laptop, error) {
+	laptops := make([]*pb.Laptop, 0, limit)
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(laptopBucket).Cursor()
+
+		key, data := cursor.Seek([]byte(afterID))
+		if key != nil && bytes.Equal(key, []byte(afterID)) {
+			key, data = cursor.Next()
+		}
+
+		for ; key != nil && len(laptops) < limit; key, data = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+			laptops = append(laptops, laptop)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return laptops, nil
+}
+
+// Search calls found after the read transaction is closed, so that slow
+// receivers don't keep it open, which would stop the database from growing.
+func (store *BoltLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	laptops := []*pb.Laptop{}
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(laptopBucket).ForEach(func(key, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("context is canceled: %w", err)
+			}
+
+			laptop, err := unmarshalLaptop(data)
+			if err != nil {
+				return err
+			}
+
+			if isQualified(filter, laptop) {
+				laptops = append(laptops, laptop)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		err := found(laptop)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmarshalLaptop(data []byte) (*pb.Laptop, error) {
+	laptop := &pb.Laptop{}
+
+	err := proto.Unmarshal(data, laptop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal laptop: %w", err)
+	}
+
+	return laptop, nil
+}
@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/sample"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBoltLaptopStore(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+
+	db, err := repository.OpenBoltDB(dataDir)
+	require.NoError(t, err)
+
+	store, err := repository.NewBoltLaptopStore(db)
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	laptop.PriceUsd = 1000
+	err = store.Save(laptop)
+	require.NoError(t, err)
+
+	err = store.Save(laptop)
+	require.ErrorIs(t, err, repository.ErrAlreadyExists)
+
+	other, err := store.Find(sample.NewLaptop().GetId())
+	require.NoError(t, err)
+	require.Nil(t, other)
+
+	require.NoError(t, db.Close())
+
+	db, err = repository.OpenBoltDB(dataDir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err = repository.NewBoltLaptopStore(db)
+	require.NoError(t, err)
+
+	other, err = store.Find(laptop.GetId())
+	require.NoError(t, err)
+	require.True(t, proto.Equal(laptop, other))
+
+	filter := &pb.Filter{MaxPriceUsd: 2000}
+	found := 0
+	err = store.Search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		found++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, found)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = store.Search(ctx, filter, func(laptop *pb.Laptop) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
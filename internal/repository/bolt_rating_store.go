@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ratingBatchSize is how many ranked ratings Top reads in a transaction.
+const ratingBatchSize = 100
+
+var (
+	ratingBucket      = []byte("ratings")
+	ratingScoreBucket = []byte("rating_scores")
+	// ratingRankBucket indexes the rated laptops by descending average score,
+	// so Top walks the laptops in order without loading every rating.
+	ratingRankBucket = []byte("rating_ranks")
+)
+
+// BoltRatingStore keeps the aggregated rating of every laptop next to the
+// score each user gave it. Add updates the scores, the aggregates and the rank
+// index in a single transaction.
+type BoltRatingStore struct {
+	db *bolt.DB
+}
+
+func NewBoltRatingStore(db *bolt.DB) (*BoltRatingStore, error) {
+	for _, name := range [][]byte{ratingBucket, ratingScoreBucket, ratingRankBucket} {
+		err := createBucket(db, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &BoltRatingStore{db: db}, nil
+}
+
+func (store *BoltRatingStore) Add(laptopID string, username string, score float64) (*Rating, error) {
+	var rating *Rating
+
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		scores, err := tx.Bucket(ratingScoreBucket).CreateBucketIfNotExists([]byte(laptopID))
+		if err != nil {
+			return fmt.Errorf("cannot create score bucket: %w", err)
+		}
+
+		rating, err = getRating(tx, laptopID)
+		if err != nil {
+			return err
+		}
+
+		ranks := tx.Bucket(ratingRankBucket)
+		if rating == nil {
+			rating = &Rating{}
+		} else {
+			err = ranks.Delete(ratingRankKey(laptopID, rating))
+			if err != nil {
+				return fmt.Errorf("cannot delete rating rank: %w", err)
+			}
+		}
+
+		if previous := scores.Get([]byte(username)); previous != nil {
+			rating.remove(decodeScore(previous))
+		}
+		rating.add(score)
+
+		err = scores.Put([]byte(username), encodeScore(score))
+		if err != nil {
+			return fmt.Errorf("cannot save score: %w", err)
+		}
+
+		data, err := json.Marshal(rating)
+		if err != nil {
+			return fmt.Errorf("cannot marshal rating: %w", err)
+		}
+
+		err = tx.Bucket(ratingBucket).Put([]byte(laptopID), data)
+		if err != nil {
+			return fmt.Errorf("cannot save rating: %w", err)
+		}
+
+		err = ranks.Put(ratingRankKey(laptopID, rating), []byte(laptopID))
+		if err != nil {
+			return fmt.Errorf("cannot save rating rank: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rating, nil
+}
+
+func (store *BoltRatingStore) Find(laptopID string) (*Rating, error) {
+	var rating *Rating
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rating, err = getRating(tx, laptopID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rating, nil
+}
+
+func (store *BoltRatingStore) Delete(laptopID string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		rating, err := getRating(tx, laptopID)
+		if err != nil {
+			return err
+		}
+
+		if rating == nil {
+			return nil
+		}
+
+		err = tx.Bucket(ratingRankBucket).Delete(ratingRankKey(laptopID, rating))
+		if err != nil {
+			return fmt.Errorf("cannot delete rating rank: %w", err)
+		}
+
+		err = tx.Bucket(ratingBucket).Delete([]byte(laptopID))
+		if err != nil {
+			return fmt.Errorf("cannot delete rating: %w", err)
+		}
+
+		err = tx.Bucket(ratingScoreBucket).DeleteBucket([]byte(laptopID))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("cannot delete scores: %w", err)
+		}
+
+		return nil
+	})
+}
+
+type rankedRating struct {
+	key      []byte
+	laptopID string
+	rating   *Rating
+}
+
+// Top reads the ranking in batches of ratingBatchSize, and calls found after
+// the read transaction of each batch is closed, so that slow receivers don't
+// keep it open, which would stop the database from growing. A laptop rated
+// while Top runs may be skipped or sent twice, as its rank moved.
+func (store *BoltRatingStore) Top(ctx context.Context, found func(laptopID string, rating *Rating) error) error {
+	var after []byte
+
+	for {
+		batch, err := store.rankBatch(ctx, after)
+		if err != nil {
+			return err
+		}
+
+		for _, ranked := range batch {
+			err := found(ranked.laptopID, ranked.rating)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < ratingBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].key
+	}
+}
+
+// rankBatch returns the ranked ratings whose keys come after the given one,
+// or the first ones when it is nil.
+func (store *BoltRatingStore) rankBatch(ctx context.Context, after []byte) ([]rankedRating, error) {
+	batch := make([]rankedRating, 0, ratingBatchSize)
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(ratingRankBucket).Cursor()
+
+		key, laptopID := cursor.First()
+		if after != nil {
+			key, laptopID = cursor.Seek(after)
+			if key != nil && bytes.Equal(key, after) {
+				key, laptopID = cursor.Next()
+			}
+		}
+
+		for ; key != nil && len(batch) < ratingBatchSize; key, laptopID = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("context is canceled: %w", err)
+			}
+
+			rating, err := getRating(tx, string(laptopID))
+			if err != nil {
+				return err
+			}
+
+			batch = append(batch, rankedRating{
+				key:      append([]byte{}, key...),
+				laptopID: string(laptopID),
+				rating:   rating,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+func getRating(tx *bolt.Tx, laptopID string) (*Rating, error) {
+	data := tx.Bucket(ratingBucket).Get([]byte(laptopID))
+	if data == nil {
+		return nil, nil
+	}
+
+	rating := &Rating{}
+	err := json.Unmarshal(data, rating)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal rating: %w", err)
+	}
+
+	return rating, nil
+}
+
+// ratingRankKey orders laptops by descending average score and then by ID.
+// The average is stored as its IEEE 754 bits, flipped so that the bytes of a
+// higher score compare lower.
+func ratingRankKey(laptopID string, rating *Rating) []byte {
+	bits := math.Float64bits(rating.Average())
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	key := make([]byte, 8, 8+len(laptopID))
+	binary.BigEndian.PutUint64(key, ^bits)
+
+	return append(key, laptopID...)
+}
+
+func encodeScore(score float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(score))
+	return data
+}
+
+func decodeScore(data []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(data))
+}
@@ -0,0 +1,198 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRatingStore(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		newStore func(t *testing.T) repository.RatingStore
+	}{
+		{
+			name: "memory",
+			newStore: func(t *testing.T) repository.RatingStore {
+				return repository.NewInMemoryRatingStore()
+			},
+		},
+		{
+			name: "bolt",
+			newStore: func(t *testing.T) repository.RatingStore {
+				db, err := repository.OpenBoltDB(t.TempDir())
+				require.NoError(t, err)
+				t.Cleanup(func() { db.Close() })
+
+				store, err := repository.NewBoltRatingStore(db)
+				require.NoError(t, err)
+				return store
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			rating, err := store.Add("laptop-1", "user1", 2)
+			require.NoError(t, err)
+			require.Equal(t, uint32(1), rating.Count)
+
+			rating, err = store.Add("laptop-1", "user2", 4)
+			require.NoError(t, err)
+			require.Equal(t, uint32(2), rating.Count)
+			require.Equal(t, 3.0, rating.Average())
+			require.Equal(t, 1.0, rating.StdDev())
+
+			rating, err = store.Add("laptop-1", "user1", 8)
+			require.NoError(t, err)
+			require.Equal(t, uint32(2), rating.Count)
+			require.Equal(t, 6.0, rating.Average())
+			require.Equal(t, map[int]uint32{4: 1, 8: 1}, rating.Histogram)
+
+			_, err = store.Add("laptop-2", "user1", 9)
+			require.NoError(t, err)
+			_, err = store.Add("laptop-3", "user1", 6)
+			require.NoError(t, err)
+
+			rating, err = store.Find("laptop-1")
+			require.NoError(t, err)
+			require.Equal(t, 6.0, rating.Average())
+			require.InDelta(t, 2.0, rating.StdDev(), 1e-9)
+
+			rating, err = store.Find("laptop-4")
+			require.NoError(t, err)
+			require.Nil(t, rating)
+
+			require.Equal(t, []string{"laptop-2", "laptop-1", "laptop-3"}, topRatedIDs(t, store))
+
+			err = store.Delete("laptop-1")
+			require.NoError(t, err)
+			err = store.Delete("laptop-4")
+			require.NoError(t, err)
+
+			rating, err = store.Find("laptop-1")
+			require.NoError(t, err)
+			require.Nil(t, rating)
+			require.Equal(t, []string{"laptop-2", "laptop-3"}, topRatedIDs(t, store))
+
+			// the scores of a deleted rating are gone as well
+			rating, err = store.Add("laptop-1", "user1", 5)
+			require.NoError(t, err)
+			require.Equal(t, uint32(1), rating.Count)
+		})
+	}
+}
+
+func TestBoltRatingStoreReopen(t *testing.T) {
+	t.Parallel()
+
+	dataDir := t.TempDir()
+
+	db, err := repository.OpenBoltDB(dataDir)
+	require.NoError(t, err)
+
+	store, err := repository.NewBoltRatingStore(db)
+	require.NoError(t, err)
+
+	_, err = store.Add("laptop-1", "user1", 5)
+	require.NoError(t, err)
+	_, err = store.Add("laptop-2", "user1", 7.5)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+
+	db, err = repository.OpenBoltDB(dataDir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err = repository.NewBoltRatingStore(db)
+	require.NoError(t, err)
+
+	rating, err := store.Add("laptop-1", "user1", 10)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), rating.Count)
+	require.Equal(t, 10.0, rating.Average())
+	require.Equal(t, map[int]uint32{10: 1}, rating.Histogram)
+
+	require.Equal(t, []string{"laptop-1", "laptop-2"}, topRatedIDs(t, store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = store.Top(ctx, func(laptopID string, rating *repository.Rating) error {
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBoltRatingStoreTopBatches(t *testing.T) {
+	t.Parallel()
+
+	db, err := repository.OpenBoltDB(t.TempDir())
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := repository.NewBoltRatingStore(db)
+	require.NoError(t, err)
+	expected := repository.NewInMemoryRatingStore()
+
+	// several batches of laptops, many of them with the same average
+	for i := 0; i < 250; i++ {
+		laptopID := fmt.Sprintf("laptop-%03d", i)
+		score := float64(i%10) + 1
+
+		_, err = store.Add(laptopID, "user1", score)
+		require.NoError(t, err)
+		_, err = expected.Add(laptopID, "user1", score)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, topRatedIDs(t, expected), topRatedIDs(t, store))
+
+	// the receiver can use the store, as no transaction is left open
+	errStop := errors.New("stop")
+	sent := 0
+	err = store.Top(context.Background(), func(laptopID string, rating *repository.Rating) error {
+		_, err := store.Add(laptopID, "user2", rating.Average())
+		require.NoError(t, err)
+
+		sent++
+		if sent == 150 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+}
+
+func TestRatingStdDev(t *testing.T) {
+	t.Parallel()
+
+	var rating *repository.Rating
+	require.Zero(t, rating.StdDev())
+
+	rating = &repository.Rating{Count: 4, Sum: 20, SumSquares: 4*25 + 8}
+	require.Equal(t, math.Sqrt(2), rating.StdDev())
+}
+
+func topRatedIDs(t *testing.T, store repository.RatingStore) []string {
+	laptopIDs := []string{}
+	err := store.Top(context.Background(), func(laptopID string, rating *repository.Rating) error {
+		laptopIDs = append(laptopIDs, laptopID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	return laptopIDs
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// reviewBucket holds a nested bucket of reviews for every laptop, keyed by
+	// creation time and ID, so the reviews of a laptop can be paged in the
+	// order they are listed in.
+	reviewBucket = []byte("reviews")
+	// reviewLaptopBucket maps review IDs to their creation time, which
+	// prefixes their key, followed by the laptop they belong to.
+	reviewLaptopBucket = []byte("review_laptops")
+)
+
+type BoltReviewStore struct {
+	db *bolt.DB
+}
+
+func NewBoltReviewStore(db *bolt.DB) (*BoltReviewStore, error) {
+	for _, name := range [][]byte{reviewBucket, reviewLaptopBucket} {
+		err := createBucket(db, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &BoltReviewStore{db: db}, nil
+}
+
+func (store *BoltReviewStore) Save(review *pb.Review) error {
+	data, err := proto.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("cannot marshal review: %w", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		laptops := tx.Bucket(reviewLaptopBucket)
+		if laptops.Get([]byte(review.GetId())) != nil {
+			return ErrAlreadyExists
+		}
+
+		reviews, err := tx.Bucket(reviewBucket).CreateBucketIfNotExists([]byte(review.GetLaptopId()))
+		if err != nil {
+			return fmt.Errorf("cannot create review bucket: %w", err)
+		}
+
+		key := reviewKey(review)
+		location := append(key[:reviewTimeSize:reviewTimeSize], review.GetLaptopId()...)
+		err = laptops.Put([]byte(review.GetId()), location)
+		if err != nil {
+			return err
+		}
+
+		return reviews.Put(key, data)
+	})
+}
+
+func (store *BoltReviewStore) Find(id string) (*pb.Review, error) {
+	var review *pb.Review
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		var err error
+		review, _, _, err = findReview(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (store *BoltReviewStore) SetHidden(id string, hidden bool) (*pb.Review, error) {
+	var review *pb.Review
+
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		var bucket *bolt.Bucket
+		var key []byte
+		review, bucket, key, err = findReview(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if review == nil {
+			return ErrNotFound
+		}
+
+		review.Hidden = hidden
+		data, err := proto.Marshal(review)
+		if err != nil {
+			return fmt.Errorf("cannot marshal review: %w", err)
+		}
+
+		return bucket.Put(key, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+func (store *BoltReviewStore) List(ctx context.Context, laptopID string, after *ReviewCursor, limit int, includeHidden bool) ([]*pb.Review, error) {
+	reviews := make([]*pb.Review, 0, limit)
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(reviewBucket).Bucket([]byte(laptopID))
+		if bucket == nil {
+			return nil
+		}
+
+		// the reviews are walked backwards, from the last key before the
+		// cursor
+		cursor := bucket.Cursor()
+		key, data := cursor.Last()
+		if after != nil {
+			key, _ = cursor.Seek(after.key())
+			if key == nil {
+				key, data = cursor.Last()
+			} else {
+				key, data = cursor.Prev()
+			}
+		}
+
+		for ; key != nil && len(reviews) < limit; key, data = cursor.Prev() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			review, err := unmarshalReview(data)
+			if err != nil {
+				return err
+			}
+
+			if includeHidden || !review.GetHidden() {
+				reviews = append(reviews, review)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (store *BoltReviewStore) DeleteLaptop(laptopID string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		reviews := tx.Bucket(reviewBucket)
+		bucket := reviews.Bucket([]byte(laptopID))
+		if bucket == nil {
+			return nil
+		}
+
+		laptops := tx.Bucket(reviewLaptopBucket)
+		err := bucket.ForEach(func(key, _ []byte) error {
+			return laptops.Delete(key[reviewTimeSize:])
+		})
+		if err != nil {
+			return fmt.Errorf("cannot delete review laptop: %w", err)
+		}
+
+		return reviews.DeleteBucket([]byte(laptopID))
+	})
+}
+
+// findReview returns the review, the bucket of its laptop and its key there.
+func findReview(tx *bolt.Tx, id string) (*pb.Review, *bolt.Bucket, []byte, error) {
+	location := tx.Bucket(reviewLaptopBucket).Get([]byte(id))
+	if len(location) < reviewTimeSize {
+		return nil, nil, nil, nil
+	}
+
+	bucket := tx.Bucket(reviewBucket).Bucket(location[reviewTimeSize:])
+	if bucket == nil {
+		return nil, nil, nil, nil
+	}
+
+	key := append(append([]byte{}, location[:reviewTimeSize]...), id...)
+	data := bucket.Get(key)
+	if data == nil {
+		return nil, nil, nil, nil
+	}
+
+	review, err := unmarshalReview(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return review, bucket, key, nil
+}
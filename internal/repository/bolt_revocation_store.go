@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var revocationBucket = []byte("revoked_tokens")
+
+type BoltRevocationStore struct {
+	db      *bolt.DB
+	mutex   sync.Mutex
+	pruning revocationPruning
+}
+
+func NewBoltRevocationStore(db *bolt.DB, opts ...RevocationStoreOption) (*BoltRevocationStore, error) {
+	err := createBucket(db, revocationBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &BoltRevocationStore{
+		db:      db,
+		pruning: newRevocationPruning(opts),
+	}
+
+	// the revocations stored by a previous run expire as well
+	store.startPruning()
+	return store, nil
+}
+
+func (store *BoltRevocationStore) Revoke(id string, expiresAt time.Time) error {
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		return putRevocation(tx.Bucket(revocationBucket), id, expiresAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	store.startPruning()
+	return nil
+}
+
+func (store *BoltRevocationStore) RevokeOnce(id string, expiresAt time.Time) (bool, error) {
+	revoked := false
+
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revocationBucket)
+		if bucket.Get([]byte(id)) != nil {
+			return nil
+		}
+
+		revoked = true
+		return putRevocation(bucket, id, expiresAt)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	store.startPruning()
+	return revoked, nil
+}
+
+func (store *BoltRevocationStore) IsRevoked(id string) (bool, error) {
+	revoked := false
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket(revocationBucket).Get([]byte(id)) != nil
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func putRevocation(bucket *bolt.Bucket, id string, expiresAt time.Time) error {
+	if previous := bucket.Get([]byte(id)); previous != nil && int64(binary.BigEndian.Uint64(previous)) >= expiresAt.Unix() {
+		return nil
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(expiresAt.Unix()))
+
+	return bucket.Put([]byte(id), data)
+}
+
+func (store *BoltRevocationStore) startPruning() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.pruning.timer == nil {
+		store.pruning.timer = time.AfterFunc(store.pruning.interval, store.prune)
+	}
+}
+
+func (store *BoltRevocationStore) prune() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	left := 0
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revocationBucket)
+
+		now := time.Now().Unix()
+		expired := [][]byte{}
+		err := bucket.ForEach(func(key, data []byte) error {
+			if int64(binary.BigEndian.Uint64(data)) < now {
+				expired = append(expired, key)
+			} else {
+				left++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			err := bucket.Delete(key)
+			if err != nil {
+				return fmt.Errorf("cannot delete expired revocation: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		store.pruning.timer = nil
+		return
+	}
+	if err != nil {
+		log.Printf("cannot prune expired revocations: %v", err)
+	}
+
+	if err == nil && left == 0 {
+		store.pruning.timer = nil
+		return
+	}
+
+	store.pruning.timer.Reset(store.pruning.interval)
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	bolt "go.etcd.io/bbolt"
+)
+
+var userBucket = []byte("users")
+
+type BoltUserStore struct {
+	db *bolt.DB
+}
+
+func NewBoltUserStore(db *bolt.DB) (*BoltUserStore, error) {
+	err := createBucket(db, userBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltUserStore{db: db}, nil
+}
+
+func (store *BoltUserStore) Save(user *entity.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("cannot marshal user: %w", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(userBucket)
+		if bucket.Get([]byte(user.Username)) != nil {
+			return ErrAlreadyExists
+		}
+
+		return bucket.Put([]byte(user.Username), data)
+	})
+}
+
+func (store *BoltUserStore) Find(username string) (*entity.User, error) {
+	var user *entity.User
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(userBucket).Get([]byte(username))
+		if data == nil {
+			return nil
+		}
+
+		var err error
+		user, err = unmarshalUser(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (store *BoltUserStore) Update(user *entity.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("cannot marshal user: %w", err)
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(userBucket)
+		if bucket.Get([]byte(user.Username)) == nil {
+			return ErrNotFound
+		}
+
+		return bucket.Put([]byte(user.Username), data)
+	})
+}
+
+func (store *BoltUserStore) Delete(username string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(userBucket)
+		if bucket.Get([]byte(username)) == nil {
+			return ErrNotFound
+		}
+
+		return bucket.Delete([]byte(username))
+	})
+}
+
+func (store *BoltUserStore) List(ctx context.Context, afterUsername string, limit int) ([]*entity.User, error) {
+	users := make([]*entity.User, 0, limit)
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(userBucket).Cursor()
+
+		key, data := cursor.Seek([]byte(afterUsername))
+		if key != nil && bytes.Equal(key, []byte(afterUsername)) {
+			key, data = cursor.Next()
+		}
+
+		for ; key != nil && len(users) < limit; key, data = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			user, err := unmarshalUser(data)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func unmarshalUser(data []byte) (*entity.User, error) {
+	user := &entity.User{}
+
+	err := json.Unmarshal(data, user)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal user: %w", err)
+	}
+
+	return user, nil
+}
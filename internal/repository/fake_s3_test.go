@@ -0,0 +1,178 @@
+package repository_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeS3AccessKey = "test-access-key"
+	fakeS3SecretKey = "test-secret-key"
+)
+
+// fakeS3 is an in-process S3-compatible server holding a single bucket. It
+// supports the path-style object and listing requests used by S3ImageStore
+// and rejects requests that are not signed with fakeS3AccessKey.
+type fakeS3 struct {
+	mutex   sync.Mutex
+	bucket  string
+	objects map[string]fakeS3Object
+}
+
+type fakeS3Object struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+type fakeS3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name          `xml:"ListBucketResult"`
+	Name        string            `xml:"Name"`
+	Prefix      string            `xml:"Prefix"`
+	KeyCount    int               `xml:"KeyCount"`
+	MaxKeys     int               `xml:"MaxKeys"`
+	IsTruncated bool              `xml:"IsTruncated"`
+	Contents    []fakeS3ListEntry `xml:"Contents"`
+}
+
+type fakeS3ListEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+}
+
+// startFakeS3 starts a fake S3 server and returns its host:port address.
+func startFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	s3 := &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string]fakeS3Object),
+	}
+
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+
+	return s3, strings.TrimPrefix(server.URL, "http://")
+}
+
+func (s3 *fakeS3) keys() []string {
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+
+	keys := []string{}
+	for key := range s3.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s3 *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	credential := "AWS4-HMAC-SHA256 Credential=" + fakeS3AccessKey + "/"
+	if !strings.HasPrefix(r.Header.Get("Authorization"), credential) {
+		writeFakeS3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s3.bucket {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s3.mutex.Lock()
+	defer s3.mutex.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodGet:
+		s3.list(w, r.URL.Query().Get("prefix"))
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeFakeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+
+		s3.objects[key] = fakeS3Object{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modified:    time.Now().UTC(),
+		}
+		w.Header().Set("ETag", fakeS3ETag(data))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := s3.objects[key]
+		if !ok {
+			writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		w.Header().Set("ETag", fakeS3ETag(object.data))
+		w.Header().Set("Last-Modified", object.modified.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s3.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s3 *fakeS3) list(w http.ResponseWriter, prefix string) {
+	result := fakeS3ListResult{
+		Name:    s3.bucket,
+		Prefix:  prefix,
+		MaxKeys: 1000,
+	}
+
+	for key, object := range s3.objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, fakeS3ListEntry{
+				Key:          key,
+				LastModified: object.modified.Format(time.RFC3339),
+				ETag:         fakeS3ETag(object.data),
+				Size:         len(object.data),
+			})
+		}
+	}
+
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func writeFakeS3Error(w http.ResponseWriter, code int, s3Code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	xml.NewEncoder(w).Encode(fakeS3Error{Code: s3Code, Message: s3Code})
+}
+
+func fakeS3ETag(data []byte) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", len(data)))
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+)
+
+const kgPerLb = 0.45359237
+
+func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.GetMaxPriceUsd() > 0 && laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
+		return false
+	}
+
+	if laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
+		return false
+	}
+
+	if laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
+		return false
+	}
+
+	if toBit(laptop.GetRam()) < toBit(filter.GetMinRam()) {
+		return false
+	}
+
+	return matchGeneral(filter, laptop) &&
+		matchCPU(filter, laptop.GetCpu()) &&
+		inMemoryRange(filter.GetRam(), laptop.GetRam()) &&
+		matchGPUs(filter, laptop.GetGpus()) &&
+		matchStorages(filter, laptop.GetStorages()) &&
+		matchScreen(filter, laptop.GetScreen()) &&
+		matchKeyboard(filter, laptop.GetKeyboard()) &&
+		inDoubleRange(filter.GetWeightKg(), weightKg(laptop))
+}
+
+func matchGeneral(filter *pb.Filter, laptop *pb.Laptop) bool {
+	return containsFold(laptop.GetName(), filter.GetNameContains()) &&
+		inStringSet(filter.GetBrands(), laptop.GetBrand()) &&
+		inDoubleRange(filter.GetPriceUsd(), laptop.GetPriceUsd()) &&
+		inUInt32Range(filter.GetReleaseYear(), laptop.GetReleaseYear())
+}
+
+func matchCPU(filter *pb.Filter, cpu *pb.CPU) bool {
+	return containsFold(cpu.GetName(), filter.GetCpuNameContains()) &&
+		inStringSet(filter.GetCpuBrands(), cpu.GetBrand()) &&
+		inUInt32Range(filter.GetCpuCores(), cpu.GetNumberCores()) &&
+		inUInt32Range(filter.GetCpuThreads(), cpu.GetNumberThreads()) &&
+		inDoubleRange(filter.GetCpuMinGhz(), cpu.GetMinGhz()) &&
+		inDoubleRange(filter.GetCpuMaxGhz(), cpu.GetMaxGhz())
+}
+
+func matchGPUs(filter *pb.Filter, gpus []*pb.GPU) bool {
+	if filter.HasGpu != nil && filter.GetHasGpu() != (len(gpus) > 0) {
+		return false
+	}
+
+	if len(filter.GetGpuBrands()) == 0 && filter.GetGpuNameContains() == "" &&
+		filter.GetGpuMinGhz() == nil && filter.GetGpuMaxGhz() == nil && filter.GetGpuMemory() == nil {
+		return true
+	}
+
+	for _, gpu := range gpus {
+		if inStringSet(filter.GetGpuBrands(), gpu.GetBrand()) &&
+			containsFold(gpu.GetName(), filter.GetGpuNameContains()) &&
+			inDoubleRange(filter.GetGpuMinGhz(), gpu.GetMinGhz()) &&
+			inDoubleRange(filter.GetGpuMaxGhz(), gpu.GetMaxGhz()) &&
+			inMemoryRange(filter.GetGpuMemory(), gpu.GetMemory()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchStorages(filter *pb.Filter, storages []*pb.Storage) bool {
+	if len(filter.GetStorageDrivers()) == 0 && filter.GetStorageCapacity() == nil {
+		return true
+	}
+
+	for _, storage := range storages {
+		if inSet(filter.GetStorageDrivers(), storage.GetDriver()) &&
+			inMemoryRange(filter.GetStorageCapacity(), storage.GetMemory()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchScreen(filter *pb.Filter, screen *pb.Screen) bool {
+	return inDoubleRange(filter.GetScreenSizeInch(), float64(screen.GetSizeInch())) &&
+		inUInt32Range(filter.GetScreenWidth(), screen.GetResolution().GetWidth()) &&
+		inUInt32Range(filter.GetScreenHeight(), screen.GetResolution().GetHeight()) &&
+		inSet(filter.GetScreenPainels(), screen.GetPainel())
+}
+
+func matchKeyboard(filter *pb.Filter, keyboard *pb.Keyboard) bool {
+	if filter.KeyboardBacklit != nil && filter.GetKeyboardBacklit() != keyboard.GetBacklit() {
+		return false
+	}
+
+	return inSet(filter.GetKeyboardLayouts(), keyboard.GetLayout())
+}
+
+func containsFold(value string, substr string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substr))
+}
+
+func inSet[T comparable](set []T, value T) bool {
+	if len(set) == 0 {
+		return true
+	}
+
+	for _, item := range set {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func inStringSet(set []string, value string) bool {
+	if len(set) == 0 {
+		return true
+	}
+
+	for _, item := range set {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func inDoubleRange(r *pb.DoubleRange, value float64) bool {
+	if r == nil {
+		return true
+	}
+
+	if r.Min != nil && value < r.GetMin() {
+		return false
+	}
+
+	return r.Max == nil || value <= r.GetMax()
+}
+
+func inUInt32Range(r *pb.UInt32Range, value uint32) bool {
+	if r == nil {
+		return true
+	}
+
+	if r.Min != nil && value < r.GetMin() {
+		return false
+	}
+
+	return r.Max == nil || value <= r.GetMax()
+}
+
+func inMemoryRange(r *pb.MemoryRange, memory *pb.Memory) bool {
+	if r.GetMin() != nil && toBit(memory) < toBit(r.GetMin()) {
+		return false
+	}
+
+	return r.GetMax() == nil || toBit(memory) <= toBit(r.GetMax())
+}
+
+func weightKg(laptop *pb.Laptop) float64 {
+	if weight, ok := laptop.GetWeight().(*pb.Laptop_WeightLb); ok {
+		return weight.WeightLb * kgPerLb
+	}
+
+	return laptop.GetWeightKg()
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIsQualified(t *testing.T) {
+	t.Parallel()
+
+	laptop := &pb.Laptop{
+		Brand: "Dell",
+		Name:  "XPS 13",
+		Cpu: &pb.CPU{
+			Brand:         "Intel",
+			NumberCores:   8,
+			Name:          "Core i7-1165G7",
+			NumberThreads: 16,
+			MinGhz:        2.5,
+			MaxGhz:        4.5,
+		},
+		Ram: &pb.Memory{Value: 16, Unit: pb.Memory_GIGABYTE},
+		Gpus: []*pb.GPU{
+			{Brand: "Nvidia", Name: "RTX 3050", MinGhz: 1.2, MaxGhz: 1.7, Memory: &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE}},
+			{Brand: "Intel", Name: "Iris Xe", MinGhz: 0.4, MaxGhz: 1.3},
+		},
+		Storages: []*pb.Storage{
+			{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 512, Unit: pb.Memory_GIGABYTE}},
+			{Driver: pb.Storage_HDD, Memory: &pb.Memory{Value: 2, Unit: pb.Memory_TERABYTE}},
+		},
+		Screen: &pb.Screen{
+			SizeInch:   13.3,
+			Resolution: &pb.Screen_Resolution{Width: 3840, Height: 2160},
+			Painel:     pb.Screen_OLED,
+		},
+		Keyboard:    &pb.Keyboard{Layout: pb.Keyboard_QWERTY, Backlit: true},
+		Weight:      &pb.Laptop_WeightLb{WeightLb: 2.8},
+		PriceUsd:    1800,
+		ReleaseYear: 2021,
+	}
+
+	testCases := []struct {
+		name     string
+		filter   *pb.Filter
+		expected bool
+	}{
+		{
+			name:     "nil_filter",
+			filter:   nil,
+			expected: true,
+		},
+		{
+			name:     "empty_filter",
+			filter:   &pb.Filter{},
+			expected: true,
+		},
+		{
+			name:     "zero_max_price",
+			filter:   &pb.Filter{MaxPriceUsd: 0, MinCpuCores: 4},
+			expected: true,
+		},
+		{
+			name:     "legacy_max_price",
+			filter:   &pb.Filter{MaxPriceUsd: 1500},
+			expected: false,
+		},
+		{
+			name:     "brand_set",
+			filter:   &pb.Filter{Brands: []string{"apple", "dell"}},
+			expected: true,
+		},
+		{
+			name:     "brand_not_in_set",
+			filter:   &pb.Filter{Brands: []string{"Lenovo"}},
+			expected: false,
+		},
+		{
+			name:     "name_contains",
+			filter:   &pb.Filter{NameContains: "xps"},
+			expected: true,
+		},
+		{
+			name:     "release_year_range",
+			filter:   &pb.Filter{ReleaseYear: &pb.UInt32Range{Min: proto.Uint32(2018), Max: proto.Uint32(2020)}},
+			expected: false,
+		},
+		{
+			name:     "price_range",
+			filter:   &pb.Filter{PriceUsd: &pb.DoubleRange{Min: proto.Float64(1000), Max: proto.Float64(2000)}},
+			expected: true,
+		},
+		{
+			name:     "cpu_threads",
+			filter:   &pb.Filter{CpuThreads: &pb.UInt32Range{Min: proto.Uint32(32)}},
+			expected: false,
+		},
+		{
+			name:     "cpu_name_contains",
+			filter:   &pb.Filter{CpuNameContains: "i7"},
+			expected: true,
+		},
+		{
+			name:     "ram_range",
+			filter:   &pb.Filter{Ram: &pb.MemoryRange{Max: &pb.Memory{Value: 8192, Unit: pb.Memory_MEGABYTE}}},
+			expected: false,
+		},
+		{
+			name:     "without_gpu",
+			filter:   &pb.Filter{HasGpu: proto.Bool(false)},
+			expected: false,
+		},
+		{
+			name: "gpu_memory",
+			filter: &pb.Filter{
+				GpuBrands: []string{"Nvidia"},
+				GpuMemory: &pb.MemoryRange{Min: &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE}},
+			},
+			expected: true,
+		},
+		{
+			name: "gpu_name_and_ghz",
+			filter: &pb.Filter{
+				GpuNameContains: "rtx",
+				GpuMinGhz:       &pb.DoubleRange{Min: proto.Float64(1)},
+				GpuMaxGhz:       &pb.DoubleRange{Max: proto.Float64(2)},
+			},
+			expected: true,
+		},
+		{
+			// no single GPU is both an Iris and that fast
+			name: "gpu_fields_of_different_gpus",
+			filter: &pb.Filter{
+				GpuNameContains: "iris",
+				GpuMinGhz:       &pb.DoubleRange{Min: proto.Float64(1)},
+			},
+			expected: false,
+		},
+		{
+			name: "ssd_capacity",
+			filter: &pb.Filter{
+				StorageDrivers:  []pb.Storage_Driver{pb.Storage_SSD},
+				StorageCapacity: &pb.MemoryRange{Min: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}},
+			},
+			expected: false,
+		},
+		{
+			name: "screen",
+			filter: &pb.Filter{
+				ScreenSizeInch: &pb.DoubleRange{Max: proto.Float64(14)},
+				ScreenWidth:    &pb.UInt32Range{Min: proto.Uint32(1920)},
+				ScreenPainels:  []pb.Screen_Painel{pb.Screen_IPS, pb.Screen_OLED},
+			},
+			expected: true,
+		},
+		{
+			name: "keyboard",
+			filter: &pb.Filter{
+				KeyboardLayouts: []pb.Keyboard_Layout{pb.Keyboard_AZERTY},
+				KeyboardBacklit: proto.Bool(true),
+			},
+			expected: false,
+		},
+		{
+			name:     "weight_in_pounds",
+			filter:   &pb.Filter{WeightKg: &pb.DoubleRange{Min: proto.Float64(1.2), Max: proto.Float64(1.3)}},
+			expected: true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, isQualified(tc.filter, laptop))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// HealthChecker is implemented by the stores that can become unavailable
+// while the server runs, such as the ones kept in a database or in S3.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+func checkBoltDB(db *bolt.DB) error {
+	err := db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("bolt database is unavailable: %w", err)
+	}
+
+	return nil
+}
+
+func (store *BoltLaptopStore) CheckHealth(ctx context.Context) error {
+	return checkBoltDB(store.db)
+}
+
+func (store *BoltRatingStore) CheckHealth(ctx context.Context) error {
+	return checkBoltDB(store.db)
+}
+
+func (store *BoltReviewStore) CheckHealth(ctx context.Context) error {
+	return checkBoltDB(store.db)
+}
+
+func (store *BoltUserStore) CheckHealth(ctx context.Context) error {
+	return checkBoltDB(store.db)
+}
+
+func (store *BoltRevocationStore) CheckHealth(ctx context.Context) error {
+	return checkBoltDB(store.db)
+}
+
+// CheckHealth checks that the image folder is still there, as it may be a
+// mounted volume.
+func (store *DiskImageStore) CheckHealth(ctx context.Context) error {
+	info, err := os.Stat(store.imageFolder)
+	if err != nil {
+		return fmt.Errorf("image folder is unavailable: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("image folder %s is not a directory", store.imageFolder)
+	}
+
+	return nil
+}
+
+func (store *S3ImageStore) CheckHealth(ctx context.Context) error {
+	exists, err := store.client.BucketExists(ctx, store.bucket)
+	if err != nil {
+		return fmt.Errorf("cannot check s3 bucket: %w", err)
+	}
+
+	if !exists {
+		return fmt.Errorf("s3 bucket %s doesn't exist", store.bucket)
+	}
+
+	return nil
+}
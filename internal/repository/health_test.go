@@ -0,0 +1,54 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStoreHealth(t *testing.T) {
+	t.Parallel()
+
+	db, err := repository.OpenBoltDB(t.TempDir())
+	require.NoError(t, err)
+
+	laptopStore, err := repository.NewBoltLaptopStore(db)
+	require.NoError(t, err)
+	ratingStore, err := repository.NewBoltRatingStore(db)
+	require.NoError(t, err)
+	reviewStore, err := repository.NewBoltReviewStore(db)
+	require.NoError(t, err)
+	userStore, err := repository.NewBoltUserStore(db)
+	require.NoError(t, err)
+	revocationStore, err := repository.NewBoltRevocationStore(db)
+	require.NoError(t, err)
+
+	stores := []repository.HealthChecker{laptopStore, ratingStore, reviewStore, userStore, revocationStore}
+	for _, store := range stores {
+		require.NoError(t, store.CheckHealth(context.Background()))
+	}
+
+	require.NoError(t, db.Close())
+	for _, store := range stores {
+		require.Error(t, store.CheckHealth(context.Background()))
+	}
+}
+
+func TestImageStoreHealth(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := filepath.Join(t.TempDir(), "images")
+	diskStore, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+	require.NoError(t, diskStore.CheckHealth(context.Background()))
+
+	require.NoError(t, os.RemoveAll(imageFolder))
+	require.Error(t, diskStore.CheckHealth(context.Background()))
+
+	s3Store, _ := newTestS3ImageStore(t)
+	require.NoError(t, s3Store.CheckHealth(context.Background()))
+}
@@ -1,64 +1,423 @@
 package repository
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type ImageStore interface {
-	Save(laptopID string, imageType string, imageData bytes.Buffer) (string, error)
+	Save(laptopID string, contentType string, imageData io.Reader) (string, error)
+	SaveVariant(id string, variant ImageVariant, imageData io.Reader) error
+	Find(id string) (*ImageInfo, error)
+	List(laptopID string) ([]*ImageInfo, error)
+	Open(id string, variant string) (io.ReadCloser, error)
+	Delete(id string) error
 }
 
+// FileImageStore is implemented by image stores that keep images in files,
+// and can take in a file staged in their staging folder by renaming it
+// instead of copying it.
+type FileImageStore interface {
+	StagingFolder() string
+	SaveFile(laptopID string, contentType string, path string) (string, error)
+}
+
+// DiskImageStore stores image files by the SHA-256 of their content, so
+// identical images share a single blob that is reference counted.
 type DiskImageStore struct {
 	mutex       sync.Mutex
 	imageFolder string
 	images      map[string]*ImageInfo
+	refs        map[string]int
 }
 
 type ImageInfo struct {
-	LaptopID string
-	Type     string
-	Path     string
+	ID          string                   `json:"id"`
+	LaptopID    string                   `json:"laptop_id"`
+	Type        string                   `json:"type"`
+	ContentType string                   `json:"content_type"`
+	Path        string                   `json:"path"`
+	Size        int64                    `json:"size"`
+	Checksum    string                   `json:"checksum"`
+	CreatedAt   time.Time                `json:"created_at"`
+	Variants    map[string]*ImageVariant `json:"variants"`
+}
+
+// ImageVariant is a resized rendition of an image.
+type ImageVariant struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+}
+
+func (info *ImageInfo) clone() *ImageInfo {
+	other := *info
+	other.Variants = make(map[string]*ImageVariant, len(info.Variants))
+	for name, variant := range info.Variants {
+		otherVariant := *variant
+		other.Variants[name] = &otherVariant
+	}
+
+	return &other
+}
+
+// imageExtensions lists the image formats that can be stored, by MIME type.
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+	"image/gif":  "gif",
+}
+
+// ImageExtension returns the file extension of an image content type, and
+// false when the content type is not an allowed image format.
+func ImageExtension(contentType string) (string, bool) {
+	extension, ok := imageExtensions[contentType]
+	return extension, ok
 }
 
-func NewDiskImageStore(imageFolder string) *DiskImageStore {
-	return &DiskImageStore{
+// NewDiskImageStore opens the image store kept in imageFolder, loading the
+// index of the images saved by previous runs.
+func NewDiskImageStore(imageFolder string) (*DiskImageStore, error) {
+	err := os.MkdirAll(imageFolder, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create image folder: %w", err)
+	}
+
+	store := &DiskImageStore{
 		imageFolder: imageFolder,
 		images:      make(map[string]*ImageInfo),
+		refs:        make(map[string]int),
+	}
+
+	err = store.loadIndex()
+	if err != nil {
+		return nil, err
 	}
+
+	return store, nil
 }
 
 func (store *DiskImageStore) Save(
-	laptopID string, imageType string, imageData bytes.Buffer,
+	laptopID string, contentType string, imageData io.Reader,
 ) (string, error) {
-	imageID, err := uuid.NewRandom()
+	imageType, ok := ImageExtension(contentType)
+	if !ok {
+		return "", fmt.Errorf("unsupported image content type %q", contentType)
+	}
+
+	temp, err := store.writeTemp(imageData)
 	if err != nil {
-		return "", fmt.Errorf("cannot generate image id: %w", err)
+		return "", err
 	}
+	defer os.Remove(temp.path)
 
-	imagePath := fmt.Sprintf("%s/%s.%s", store.imageFolder, imageID, imageType)
+	return store.add(laptopID, contentType, imageType, temp)
+}
+
+func (store *DiskImageStore) StagingFolder() string {
+	return store.imageFolder
+}
 
-	file, err := os.Create(imagePath)
+// SaveFile stores the image of a file in the staging folder, which is moved
+// into place, or removed when the same content is already stored.
+func (store *DiskImageStore) SaveFile(laptopID string, contentType string, path string) (string, error) {
+	if filepath.Dir(path) != filepath.Clean(store.imageFolder) {
+		return "", fmt.Errorf("image file %s is not in the image folder", path)
+	}
+	defer os.Remove(path)
+
+	imageType, ok := ImageExtension(contentType)
+	if !ok {
+		return "", fmt.Errorf("unsupported image content type %q", contentType)
+	}
+
+	temp, err := hashFile(path)
 	if err != nil {
-		return "", fmt.Errorf("cannot create image file: %w", err)
+		return "", err
 	}
-	_, err = imageData.WriteTo(file)
+
+	return store.add(laptopID, contentType, imageType, temp)
+}
+
+// add indexes a new image whose content is in temp.
+func (store *DiskImageStore) add(laptopID string, contentType string, imageType string, temp *tempFile) (string, error) {
+	imageID, err := uuid.NewRandom()
 	if err != nil {
-		return "", fmt.Errorf("cannot write image to file: %w", err)
+		return "", fmt.Errorf("cannot generate image id: %w", err)
 	}
 
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
+	imagePath, err := store.linkBlob(temp, imageType)
+	if err != nil {
+		return "", err
+	}
+
 	store.images[imageID.String()] = &ImageInfo{
-		LaptopID: laptopID,
-		Type:     imageType,
-		Path:     imagePath,
+		ID:          imageID.String(),
+		LaptopID:    laptopID,
+		Type:        imageType,
+		ContentType: contentType,
+		Path:        imagePath,
+		Size:        temp.size,
+		Checksum:    temp.checksum,
+		CreatedAt:   time.Now().UTC(),
+		Variants:    make(map[string]*ImageVariant),
+	}
+
+	err = store.saveIndex()
+	if err != nil {
+		delete(store.images, imageID.String())
+		store.unlinkBlob(imagePath)
+		return "", err
 	}
 
 	return imageID.String(), nil
 }
+
+// SaveVariant stores a resized rendition next to the original image,
+// replacing any previous variant with the same name.
+func (store *DiskImageStore) SaveVariant(id string, variant ImageVariant, imageData io.Reader) error {
+	imageType, ok := ImageExtension(variant.ContentType)
+	if !ok {
+		return fmt.Errorf("unsupported image content type %q", variant.ContentType)
+	}
+
+	temp, err := store.writeTemp(imageData)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.path)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	info, ok := store.images[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	variant.Path, err = store.linkBlob(temp, imageType)
+	if err != nil {
+		return err
+	}
+	variant.Size = temp.size
+	variant.Checksum = temp.checksum
+
+	previous, replaced := info.Variants[variant.Name]
+	info.Variants[variant.Name] = &variant
+
+	err = store.saveIndex()
+	if err != nil {
+		if replaced {
+			info.Variants[variant.Name] = previous
+		} else {
+			delete(info.Variants, variant.Name)
+		}
+		store.unlinkBlob(variant.Path)
+		return err
+	}
+
+	if replaced {
+		return store.unlinkBlob(previous.Path)
+	}
+
+	return nil
+}
+
+type tempFile struct {
+	path     string
+	size     int64
+	checksum string
+}
+
+// writeTemp streams data into a temporary file in the image folder, hashing
+// it on the way. The caller must remove the file once it is done with it.
+func (store *DiskImageStore) writeTemp(data io.Reader) (*tempFile, error) {
+	file, err := os.CreateTemp(store.imageFolder, ".image-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create image file: %w", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("cannot write image to file: %w", err)
+	}
+
+	return &tempFile{
+		path:     file.Name(),
+		size:     size,
+		checksum: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// hashFile hashes a file that is ready to be linked as a blob.
+func hashFile(path string) (*tempFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open image file: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read image file: %w", err)
+	}
+
+	return &tempFile{
+		path:     path,
+		size:     size,
+		checksum: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// linkBlob adds a reference to the blob holding the content of temp, moving
+// temp into place when no such blob is stored yet. It must be called with
+// the mutex held.
+func (store *DiskImageStore) linkBlob(temp *tempFile, imageType string) (string, error) {
+	blobPath := filepath.Join(store.imageFolder, fmt.Sprintf("%s.%s", temp.checksum, imageType))
+
+	if store.refs[blobPath] == 0 {
+		err := os.Rename(temp.path, blobPath)
+		if err != nil {
+			return "", fmt.Errorf("cannot move image file into place: %w", err)
+		}
+	}
+
+	store.refs[blobPath]++
+	return blobPath, nil
+}
+
+// unlinkBlob drops a reference to a blob and deletes it once nothing refers
+// to it anymore. It must be called with the mutex held.
+func (store *DiskImageStore) unlinkBlob(blobPath string) error {
+	store.refs[blobPath]--
+	if store.refs[blobPath] > 0 {
+		return nil
+	}
+
+	delete(store.refs, blobPath)
+
+	err := os.Remove(blobPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot delete image file: %w", err)
+	}
+
+	return nil
+}
+
+func (store *DiskImageStore) Find(id string) (*ImageInfo, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	info, ok := store.images[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return info.clone(), nil
+}
+
+// List returns the images of a laptop ordered by ID.
+func (store *DiskImageStore) List(laptopID string) ([]*ImageInfo, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	images := []*ImageInfo{}
+	for _, info := range store.images {
+		if info.LaptopID == laptopID {
+			images = append(images, info.clone())
+		}
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+
+	return images, nil
+}
+
+// Open returns the original image when variant is empty, or the named
+// resized rendition otherwise. The file is opened under the lock, so that a
+// concurrent Delete cannot unlink it in between.
+func (store *DiskImageStore) Open(id string, variant string) (io.ReadCloser, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	info, ok := store.images[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	path := info.Path
+	if variant != "" {
+		imageVariant, ok := info.Variants[variant]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		path = imageVariant.Path
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open image file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (store *DiskImageStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	info, ok := store.images[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(store.images, id)
+
+	err := store.saveIndex()
+	if err != nil {
+		store.images[id] = info
+		return err
+	}
+
+	err = store.unlinkBlob(info.Path)
+	for _, variant := range info.Variants {
+		if variantErr := store.unlinkBlob(variant.Path); err == nil {
+			err = variantErr
+		}
+	}
+
+	return err
+}
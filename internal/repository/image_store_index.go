@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const imageIndexFile = ".index.json"
+
+// ImageStoreReport describes the inconsistencies found between the image
+// index and the files in the image folder.
+type ImageStoreReport struct {
+	// OrphanFiles are files in the image folder that no image refers to.
+	OrphanFiles []string
+	// MissingBlobs are the images, or image/variant pairs, whose file is gone.
+	MissingBlobs []string
+}
+
+// loadIndex reads the index written by saveIndex, if there is one, and
+// counts the references to each blob.
+func (store *DiskImageStore) loadIndex() error {
+	data, err := os.ReadFile(filepath.Join(store.imageFolder, imageIndexFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot read image index: %w", err)
+	}
+
+	images := []*ImageInfo{}
+	err = json.Unmarshal(data, &images)
+	if err != nil {
+		return fmt.Errorf("cannot decode image index: %w", err)
+	}
+
+	for _, info := range images {
+		info.Path = filepath.Join(store.imageFolder, info.Path)
+		store.refs[info.Path]++
+
+		if info.Variants == nil {
+			info.Variants = make(map[string]*ImageVariant)
+		}
+
+		for _, variant := range info.Variants {
+			variant.Path = filepath.Join(store.imageFolder, variant.Path)
+			store.refs[variant.Path]++
+		}
+
+		store.images[info.ID] = info
+	}
+
+	return nil
+}
+
+// saveIndex atomically rewrites the index with the current images, storing
+// paths relative to the image folder. It must be called with the mutex held.
+func (store *DiskImageStore) saveIndex() error {
+	images := make([]*ImageInfo, 0, len(store.images))
+	for _, info := range store.images {
+		record := info.clone()
+		record.Path = filepath.Base(record.Path)
+		for _, variant := range record.Variants {
+			variant.Path = filepath.Base(variant.Path)
+		}
+
+		images = append(images, record)
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+
+	data, err := json.MarshalIndent(images, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode image index: %w", err)
+	}
+
+	file, err := os.CreateTemp(store.imageFolder, ".index-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create image index: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot write image index: %w", err)
+	}
+
+	err = os.Rename(file.Name(), filepath.Join(store.imageFolder, imageIndexFile))
+	if err != nil {
+		return fmt.Errorf("cannot move image index into place: %w", err)
+	}
+
+	return nil
+}
+
+// Reconcile checks the index against the image folder. Images whose files
+// are gone are dropped from the index and temporary files left behind by an
+// interrupted save are deleted, so it must run before the store is used. The
+// files of uploads staged in the folder are left to the upload store.
+// Orphan files are only reported, since they cannot be linked back to a
+// laptop.
+func (store *DiskImageStore) Reconcile() (*ImageStoreReport, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	report := &ImageStoreReport{}
+	changed := false
+
+	for _, info := range store.images {
+		if !fileExists(info.Path) {
+			report.MissingBlobs = append(report.MissingBlobs, info.ID)
+			changed = true
+
+			delete(store.images, info.ID)
+			store.dropRef(info.Path)
+			for _, variant := range info.Variants {
+				store.unlinkBlob(variant.Path)
+			}
+			continue
+		}
+
+		for name, variant := range info.Variants {
+			if !fileExists(variant.Path) {
+				report.MissingBlobs = append(report.MissingBlobs, info.ID+"/"+name)
+				changed = true
+
+				delete(info.Variants, name)
+				store.dropRef(variant.Path)
+			}
+		}
+	}
+
+	if changed {
+		err := store.saveIndex()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(store.imageFolder)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read image folder: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(store.imageFolder, name)
+
+		if entry.IsDir() || name == imageIndexFile || isUploadFile(name) {
+			continue
+		}
+
+		if isTempFile(name) {
+			os.Remove(path)
+			continue
+		}
+
+		if store.refs[path] == 0 {
+			report.OrphanFiles = append(report.OrphanFiles, path)
+		}
+	}
+
+	sort.Strings(report.MissingBlobs)
+	sort.Strings(report.OrphanFiles)
+
+	return report, nil
+}
+
+// dropRef forgets a reference to a blob whose file is already gone.
+func (store *DiskImageStore) dropRef(blobPath string) {
+	store.refs[blobPath]--
+	if store.refs[blobPath] <= 0 {
+		delete(store.refs, blobPath)
+	}
+}
+
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, ".tmp") &&
+		(strings.HasPrefix(name, ".image-") || strings.HasPrefix(name, ".index-") ||
+			strings.HasPrefix(name, ".upload-"))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
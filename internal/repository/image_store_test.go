@@ -0,0 +1,421 @@
+package repository_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskImageStoreSave(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	imageData := bytes.Repeat([]byte("laptop"), 1<<20)
+	imageID, err := store.Save("laptop-id", "image/jpeg", bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	info, err := store.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(imageData)), info.Size)
+	require.FileExists(t, info.Path)
+
+	reader, err := store.Open(imageID, "")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	savedData, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, imageData, savedData)
+
+	requireImageFiles(t, imageFolder, 1)
+}
+
+func TestDiskImageStoreSaveFailure(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	imageData := io.MultiReader(
+		bytes.NewReader([]byte("partial image")),
+		&failingReader{err: errors.New("connection lost")},
+	)
+	_, err = store.Save("laptop-id", "image/jpeg", imageData)
+	require.Error(t, err)
+
+	images, err := store.List("laptop-id")
+	require.NoError(t, err)
+	require.Empty(t, images)
+
+	requireImageFiles(t, imageFolder, 0)
+}
+
+func TestDiskImageStoreDeduplication(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	imageData := []byte("the same photo for every laptop")
+	imageID1, err := store.Save("laptop-1", "image/jpeg", bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	imageID2, err := store.Save("laptop-2", "image/jpeg", bytes.NewReader(imageData))
+	require.NoError(t, err)
+	require.NotEqual(t, imageID1, imageID2)
+
+	otherID, err := store.Save("laptop-1", "image/jpeg", bytes.NewReader([]byte("another photo")))
+	require.NoError(t, err)
+
+	info1, err := store.Find(imageID1)
+	require.NoError(t, err)
+	info2, err := store.Find(imageID2)
+	require.NoError(t, err)
+	require.Equal(t, info1.Path, info2.Path)
+	require.Equal(t, info1.Checksum, info2.Checksum)
+
+	variant := repository.ImageVariant{Name: "thumbnail", ContentType: "image/png"}
+	err = store.SaveVariant(imageID1, variant, bytes.NewReader([]byte("small photo")))
+	require.NoError(t, err)
+	err = store.SaveVariant(imageID2, variant, bytes.NewReader([]byte("small photo")))
+	require.NoError(t, err)
+
+	requireImageFiles(t, imageFolder, 3)
+
+	err = store.Delete(imageID1)
+	require.NoError(t, err)
+	requireImageFiles(t, imageFolder, 3)
+
+	reader, err := store.Open(imageID2, "")
+	require.NoError(t, err)
+	savedData, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, imageData, savedData)
+
+	err = store.Delete(imageID2)
+	require.NoError(t, err)
+	requireImageFiles(t, imageFolder, 1)
+
+	err = store.Delete(otherID)
+	require.NoError(t, err)
+	requireImageFiles(t, imageFolder, 0)
+}
+
+func TestDiskImageStoreOpenWhileDeleting(t *testing.T) {
+	t.Parallel()
+
+	store, err := repository.NewDiskImageStore(t.TempDir())
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		imageID, err := store.Save("laptop-1", "image/jpeg", bytes.NewReader([]byte(fmt.Sprintf("photo %d", i))))
+		require.NoError(t, err)
+
+		opened := make(chan error)
+		go func() {
+			reader, err := store.Open(imageID, "")
+			if err == nil {
+				reader.Close()
+			}
+			opened <- err
+		}()
+
+		err = store.Delete(imageID)
+		require.NoError(t, err)
+
+		// the image is either opened before it is deleted or not found
+		err = <-opened
+		if err != nil {
+			require.ErrorIs(t, err, repository.ErrNotFound)
+		}
+	}
+}
+
+func TestDiskImageStoreReopen(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	imageID, err := store.Save("laptop-1", "image/jpeg", bytes.NewReader([]byte("photo")))
+	require.NoError(t, err)
+
+	variant := repository.ImageVariant{Name: "thumbnail", ContentType: "image/png", Width: 1, Height: 1}
+	err = store.SaveVariant(imageID, variant, bytes.NewReader([]byte("small photo")))
+	require.NoError(t, err)
+
+	lostID, err := store.Save("laptop-1", "image/png", bytes.NewReader([]byte("lost photo")))
+	require.NoError(t, err)
+
+	saved, err := store.Find(imageID)
+	require.NoError(t, err)
+	lost, err := store.Find(lostID)
+	require.NoError(t, err)
+
+	err = os.Remove(lost.Path)
+	require.NoError(t, err)
+
+	orphanPath := filepath.Join(imageFolder, "orphan.jpg")
+	err = os.WriteFile(orphanPath, []byte("orphan"), 0o644)
+	require.NoError(t, err)
+
+	tempPath := filepath.Join(imageFolder, ".image-123.tmp")
+	err = os.WriteFile(tempPath, []byte("partial"), 0o644)
+	require.NoError(t, err)
+
+	store, err = repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	report, err := store.Reconcile()
+	require.NoError(t, err)
+	require.Equal(t, []string{lostID}, report.MissingBlobs)
+	require.Equal(t, []string{orphanPath}, report.OrphanFiles)
+	require.NoFileExists(t, tempPath)
+
+	reloaded, err := store.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, saved, reloaded)
+	require.False(t, reloaded.CreatedAt.IsZero())
+
+	images, err := store.List("laptop-1")
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+
+	store, err = repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	report, err = store.Reconcile()
+	require.NoError(t, err)
+	require.Empty(t, report.MissingBlobs)
+	require.Equal(t, []string{orphanPath}, report.OrphanFiles)
+
+	err = store.Delete(imageID)
+	require.NoError(t, err)
+	requireImageFiles(t, imageFolder, 1)
+}
+
+func TestDiskImageStoreSaveFile(t *testing.T) {
+	t.Parallel()
+
+	imageFolder := t.TempDir()
+	store, err := repository.NewDiskImageStore(imageFolder)
+	require.NoError(t, err)
+
+	stage := func(data string) string {
+		path := filepath.Join(imageFolder, ".upload-test.tmp")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+
+	path := stage("photo")
+	imageID, err := store.SaveFile("laptop-1", "image/jpeg", path)
+	require.NoError(t, err)
+	require.NoFileExists(t, path)
+
+	info, err := store.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, int64(len("photo")), info.Size)
+	requireImageFiles(t, imageFolder, 1)
+
+	// the same content is linked to the stored blob
+	path = stage("photo")
+	otherID, err := store.SaveFile("laptop-2", "image/jpeg", path)
+	require.NoError(t, err)
+	require.NoFileExists(t, path)
+
+	other, err := store.Find(otherID)
+	require.NoError(t, err)
+	require.Equal(t, info.Path, other.Path)
+	requireImageFiles(t, imageFolder, 1)
+
+	outside := filepath.Join(t.TempDir(), "photo.jpg")
+	require.NoError(t, os.WriteFile(outside, []byte("other photo"), 0o600))
+	_, err = store.SaveFile("laptop-1", "image/jpeg", outside)
+	require.Error(t, err)
+	require.FileExists(t, outside)
+	requireImageFiles(t, imageFolder, 1)
+}
+
+func TestDiskUploadStoreAppend(t *testing.T) {
+	t.Parallel()
+
+	uploadFolder := t.TempDir()
+	store := repository.NewDiskUploadStore(uploadFolder)
+
+	upload, err := store.Create("laptop-id", "jpg", "")
+	require.NoError(t, err)
+
+	_, err = store.Append(upload.ID, 0, []byte("hello "))
+	require.Error(t, err)
+
+	_, err = store.Acquire(upload.ID)
+	require.NoError(t, err)
+
+	_, err = store.Acquire(upload.ID)
+	require.ErrorIs(t, err, repository.ErrUploadInProgress)
+
+	offset, err := store.Append(upload.ID, 0, []byte("hello "))
+	require.NoError(t, err)
+	require.Equal(t, int64(6), offset)
+
+	_, err = store.Append(upload.ID, 0, []byte("again"))
+	require.ErrorIs(t, err, repository.ErrOffsetMismatch)
+
+	// a later stream continues where the previous one stopped
+	require.NoError(t, store.Release(upload.ID))
+	acquired, err := store.Acquire(upload.ID)
+	require.NoError(t, err)
+	require.Equal(t, offset, acquired.Offset)
+
+	offset, err = store.Append(upload.ID, offset, []byte("world"))
+	require.NoError(t, err)
+	require.Equal(t, int64(11), offset)
+
+	reader, err := store.Open(upload.ID)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "hello world", string(data))
+
+	err = store.Delete(upload.ID)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(uploadFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestDiskUploadStoreExpiry(t *testing.T) {
+	t.Parallel()
+
+	uploadFolder := t.TempDir()
+	store := repository.NewDiskUploadStore(uploadFolder, repository.WithUploadTTL(50*time.Millisecond))
+
+	abandoned, err := store.Create("laptop-id", "jpg", "")
+	require.NoError(t, err)
+
+	acquired, err := store.Create("laptop-id", "jpg", "")
+	require.NoError(t, err)
+	_, err = store.Acquire(acquired.ID)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		upload, err := store.Find(abandoned.ID)
+		return err == nil && upload == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// an acquired upload is kept however long its stream lasts
+	upload, err := store.Find(acquired.ID)
+	require.NoError(t, err)
+	require.NotNil(t, upload)
+
+	require.NoError(t, store.Release(acquired.ID))
+	require.Eventually(t, func() bool {
+		upload, err := store.Find(acquired.ID)
+		return err == nil && upload == nil
+	}, time.Second, 10*time.Millisecond)
+
+	entries, err := os.ReadDir(uploadFolder)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// requireImageFiles checks the number of image files in the folder, leaving
+// out hidden files such as the index.
+func requireImageFiles(t *testing.T, imageFolder string, count int) {
+	entries, err := os.ReadDir(imageFolder)
+	require.NoError(t, err)
+
+	files := 0
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			files++
+		}
+	}
+	require.Equal(t, count, files)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDiskUploadStoreRestart(t *testing.T) {
+	t.Parallel()
+
+	uploadFolder := t.TempDir()
+	store := repository.NewDiskUploadStore(uploadFolder)
+
+	upload, err := store.Create("laptop-id", "image/jpeg", "checksum")
+	require.NoError(t, err)
+
+	_, err = store.Acquire(upload.ID)
+	require.NoError(t, err)
+	offset, err := store.Append(upload.ID, 0, []byte("hello "))
+	require.NoError(t, err)
+	require.NoError(t, store.Release(upload.ID))
+
+	// a staged file whose record was never written
+	partial := filepath.Join(uploadFolder, ".upload-partial.part")
+	require.NoError(t, os.WriteFile(partial, []byte("partial"), 0o600))
+
+	// the image store shares the folder and leaves the upload alone
+	imageStore, err := repository.NewDiskImageStore(uploadFolder)
+	require.NoError(t, err)
+	report, err := imageStore.Reconcile()
+	require.NoError(t, err)
+	require.Empty(t, report.OrphanFiles)
+
+	store = repository.NewDiskUploadStore(uploadFolder)
+	require.NoFileExists(t, partial)
+
+	restored, err := store.Find(upload.ID)
+	require.NoError(t, err)
+	require.NotNil(t, restored)
+	require.Equal(t, offset, restored.Offset)
+	require.Equal(t, upload.LaptopID, restored.LaptopID)
+	require.Equal(t, upload.ContentType, restored.ContentType)
+	require.Equal(t, upload.Checksum, restored.Checksum)
+
+	_, err = store.Acquire(upload.ID)
+	require.NoError(t, err)
+	_, err = store.Append(upload.ID, offset, []byte("world"))
+	require.NoError(t, err)
+
+	reader, err := store.Open(upload.ID)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "hello world", string(data))
+
+	require.NoError(t, store.Delete(upload.ID))
+
+	entries, err := os.ReadDir(uploadFolder)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.False(t, strings.HasPrefix(entry.Name(), ".upload-"), entry.Name())
+	}
+}
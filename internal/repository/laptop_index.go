@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+)
+
+type indexValue interface {
+	~uint32 | ~uint64 | ~float64
+}
+
+type indexEntry[T indexValue] struct {
+	value T
+	id    string
+}
+
+// sortedIndex keeps laptop IDs ordered by value, then by ID.
+type sortedIndex[T indexValue] struct {
+	entries []indexEntry[T]
+}
+
+func (index *sortedIndex[T]) position(value T, id string) int {
+	return sort.Search(len(index.entries), func(i int) bool {
+		entry := index.entries[i]
+		return entry.value > value || (entry.value == value && entry.id >= id)
+	})
+}
+
+func (index *sortedIndex[T]) insert(value T, id string) {
+	i := index.position(value, id)
+	index.entries = append(index.entries, indexEntry[T]{})
+	copy(index.entries[i+1:], index.entries[i:])
+	index.entries[i] = indexEntry[T]{value: value, id: id}
+}
+
+func (index *sortedIndex[T]) remove(value T, id string) {
+	i := index.position(value, id)
+	if i < len(index.entries) && index.entries[i].id == id {
+		index.entries = append(index.entries[:i], index.entries[i+1:]...)
+	}
+}
+
+// between returns the entries with min <= value <= max. A nil bound is open.
+func (index *sortedIndex[T]) between(min, max *T) []indexEntry[T] {
+	start := 0
+	if min != nil {
+		start = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value >= *min
+		})
+	}
+
+	end := len(index.entries)
+	if max != nil {
+		end = sort.Search(len(index.entries), func(i int) bool {
+			return index.entries[i].value > *max
+		})
+	}
+
+	if start >= end {
+		return nil
+	}
+
+	return index.entries[start:end]
+}
+
+type candidateSet struct {
+	size int
+	ids  func() []string
+}
+
+func newCandidateSet[T indexValue](entries []indexEntry[T]) candidateSet {
+	return candidateSet{
+		size: len(entries),
+		ids: func() []string {
+			ids := make([]string, len(entries))
+			for i, entry := range entries {
+				ids[i] = entry.id
+			}
+			return ids
+		},
+	}
+}
+
+type laptopIndexes struct {
+	price    sortedIndex[float64]
+	cpuCores sortedIndex[uint32]
+	cpuGhz   sortedIndex[float64]
+	ramBits  sortedIndex[uint64]
+}
+
+func (indexes *laptopIndexes) add(laptop *pb.Laptop) {
+	indexes.price.insert(laptop.GetPriceUsd(), laptop.GetId())
+	indexes.cpuCores.insert(laptop.GetCpu().GetNumberCores(), laptop.GetId())
+	indexes.cpuGhz.insert(laptop.GetCpu().GetMinGhz(), laptop.GetId())
+	indexes.ramBits.insert(toBit(laptop.GetRam()), laptop.GetId())
+}
+
+func (indexes *laptopIndexes) remove(laptop *pb.Laptop) {
+	indexes.price.remove(laptop.GetPriceUsd(), laptop.GetId())
+	indexes.cpuCores.remove(laptop.GetCpu().GetNumberCores(), laptop.GetId())
+	indexes.cpuGhz.remove(laptop.GetCpu().GetMinGhz(), laptop.GetId())
+	indexes.ramBits.remove(toBit(laptop.GetRam()), laptop.GetId())
+}
+
+// candidates returns the IDs of the laptops that can match the filter, using
+// the most selective index. It returns false when no index applies.
+func (indexes *laptopIndexes) candidates(filter *pb.Filter) ([]string, bool) {
+	sets := []candidateSet{}
+
+	if min, max := priceBounds(filter); min != nil || max != nil {
+		sets = append(sets, newCandidateSet(indexes.price.between(min, max)))
+	}
+
+	if min, max := cpuCoresBounds(filter); min != nil || max != nil {
+		sets = append(sets, newCandidateSet(indexes.cpuCores.between(min, max)))
+	}
+
+	if min, max := cpuGhzBounds(filter); min != nil || max != nil {
+		sets = append(sets, newCandidateSet(indexes.cpuGhz.between(min, max)))
+	}
+
+	if min, max := ramBounds(filter); min != nil || max != nil {
+		sets = append(sets, newCandidateSet(indexes.ramBits.between(min, max)))
+	}
+
+	if len(sets) == 0 {
+		return nil, false
+	}
+
+	best := sets[0]
+	for _, set := range sets[1:] {
+		if set.size < best.size {
+			best = set
+		}
+	}
+
+	return best.ids(), true
+}
+
+func priceBounds(filter *pb.Filter) (min, max *float64) {
+	if filter.GetMaxPriceUsd() > 0 {
+		maxPrice := filter.GetMaxPriceUsd()
+		max = &maxPrice
+	}
+
+	rangeMin, rangeMax := doubleBounds(filter.GetPriceUsd())
+	return tighten(rangeMin, rangeMax, nil, max)
+}
+
+func cpuCoresBounds(filter *pb.Filter) (min, max *uint32) {
+	if filter.GetMinCpuCores() > 0 {
+		minCores := filter.GetMinCpuCores()
+		min = &minCores
+	}
+
+	rangeMin, rangeMax := uint32Bounds(filter.GetCpuCores())
+	return tighten(rangeMin, rangeMax, min, nil)
+}
+
+func cpuGhzBounds(filter *pb.Filter) (min, max *float64) {
+	if filter.GetMinCpuGhz() > 0 {
+		minGhz := filter.GetMinCpuGhz()
+		min = &minGhz
+	}
+
+	rangeMin, rangeMax := doubleBounds(filter.GetCpuMinGhz())
+	return tighten(rangeMin, rangeMax, min, nil)
+}
+
+func ramBounds(filter *pb.Filter) (min, max *uint64) {
+	if minBits := toBit(filter.GetMinRam()); minBits > 0 {
+		min = &minBits
+	}
+
+	if filter.GetRam().GetMin() != nil {
+		rangeMin := toBit(filter.GetRam().GetMin())
+		if min == nil || rangeMin > *min {
+			min = &rangeMin
+		}
+	}
+
+	if filter.GetRam().GetMax() != nil {
+		rangeMax := toBit(filter.GetRam().GetMax())
+		max = &rangeMax
+	}
+
+	return min, max
+}
+
+func doubleBounds(r *pb.DoubleRange) (min, max *float64) {
+	if r == nil {
+		return nil, nil
+	}
+
+	return r.Min, r.Max
+}
+
+func uint32Bounds(r *pb.UInt32Range) (min, max *uint32) {
+	if r == nil {
+		return nil, nil
+	}
+
+	return r.Min, r.Max
+}
+
+// tighten merges two pairs of optional bounds into the narrowest one.
+func tighten[T indexValue](min1, max1, min2, max2 *T) (min, max *T) {
+	min, max = min1, max1
+
+	if min2 != nil && (min == nil || *min2 > *min) {
+		min = min2
+	}
+
+	if max2 != nil && (max == nil || *max2 < *max) {
+		max = max2
+	}
+
+	return min, max
+}
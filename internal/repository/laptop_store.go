@@ -4,23 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
-	"github.com/jinzhu/copier"
+	"google.golang.org/protobuf/proto"
 )
 
-var ErrAlreadyExists = errors.New("record already exists")
+var (
+	ErrAlreadyExists = errors.New("record already exists")
+	ErrNotFound      = errors.New("record not found")
+)
 
 type LaptopStore interface {
 	Save(laptop *pb.Laptop) error
 	Find(id string) (*pb.Laptop, error)
+	Update(laptop *pb.Laptop) error
+	Delete(id string) error
+	List(ctx context.Context, afterID string, limit int) ([]*pb.Laptop, error)
+	// Search calls found with the qualified laptops in ID order, so the same
+	// search always finds them in the same order.
 	Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error
 }
 
 type InMemoryLaptopStore struct {
-	mutex sync.RWMutex
-	data  map[string]*pb.Laptop
+	mutex   sync.RWMutex
+	data    map[string]*pb.Laptop
+	indexes laptopIndexes
 }
 
 func NewInMemoryLaptopStore() *InMemoryLaptopStore {
@@ -43,6 +53,7 @@ func (store *InMemoryLaptopStore) Save(laptop *pb.Laptop) error {
 	}
 
 	store.data[other.Id] = other
+	store.indexes.add(other)
 	return nil
 }
 
@@ -58,51 +69,148 @@ func (store *InMemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
 	return deepCopy(laptop)
 }
 
-func (store *InMemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+func (store *InMemoryLaptopStore) Update(laptop *pb.Laptop) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	previous := store.data[laptop.Id]
+	if previous == nil {
+		return ErrNotFound
+	}
+
+	other, err := deepCopy(laptop)
+	if err != nil {
+		return err
+	}
+
+	store.indexes.remove(previous)
+	store.data[other.Id] = other
+	store.indexes.add(other)
+	return nil
+}
+
+func (store *InMemoryLaptopStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	laptop := store.data[id]
+	if laptop == nil {
+		return ErrNotFound
+	}
+
+	store.indexes.remove(laptop)
+	delete(store.data, id)
+	return nil
+}
+
+// List returns up to limit laptops ordered by ID, starting right after afterID.
+func (store *InMemoryLaptopStore) List(ctx context.Context, afterID string, limit int) ([]*pb.Laptop, error) {
 	store.mutex.RLock()
 	defer store.mutex.RUnlock()
 
-	for _, laptop := range store.data {
+	ids := make([]string, 0, len(store.data))
+	for id := range store.data {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
 
-		if ctx.Err() == context.Canceled || ctx.Err() == context.DeadlineExceeded {
-			fmt.Println("context is canceled")
-			return errors.New("context is canceled")
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	laptops := make([]*pb.Laptop, 0, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		other, err := deepCopy(store.data[id])
+		if err != nil {
+			return nil, err
 		}
+		laptops = append(laptops, other)
+	}
+
+	return laptops, nil
+}
 
-		if isQualified(filter, laptop) {
-			other, err := deepCopy(laptop)
-			if err != nil {
-				return err
-			}
+func (store *InMemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return store.search(ctx, filter, found, store.indexedCandidates)
+}
 
-			err = found(other)
-			if err != nil {
-				return err
-			}
+// search calls found outside of the read lock, so that slow receivers do not
+// block Save.
+func (store *InMemoryLaptopStore) search(
+	ctx context.Context,
+	filter *pb.Filter,
+	found func(laptop *pb.Laptop) error,
+	candidates func(filter *pb.Filter) []string,
+) error {
+	laptops, err := store.qualified(ctx, filter, candidates)
+	if err != nil {
+		return err
+	}
+
+	for _, laptop := range laptops {
+		err := found(laptop)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
-	if laptop.GetPriceUsd() > filter.GetMaxPriceUsd() {
-		return false
-	}
+func (store *InMemoryLaptopStore) qualified(
+	ctx context.Context,
+	filter *pb.Filter,
+	candidates func(filter *pb.Filter) []string,
+) ([]*pb.Laptop, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	ids := candidates(filter)
+	sort.Strings(ids)
+
+	laptops := []*pb.Laptop{}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("context is canceled: %w", err)
+		}
+
+		laptop := store.data[id]
+		if laptop == nil || !isQualified(filter, laptop) {
+			continue
+		}
 
-	if laptop.GetCpu().GetNumberCores() < filter.GetMinCpuCores() {
-		return false
+		other, err := deepCopy(laptop)
+		if err != nil {
+			return nil, err
+		}
+		laptops = append(laptops, other)
 	}
 
-	if laptop.GetCpu().GetMinGhz() < filter.GetMinCpuGhz() {
-		return false
+	return laptops, nil
+}
+
+func (store *InMemoryLaptopStore) indexedCandidates(filter *pb.Filter) []string {
+	ids, ok := store.indexes.candidates(filter)
+	if !ok {
+		return store.scanCandidates(filter)
 	}
 
-	if toBit(laptop.GetRam()) < toBit(filter.GetMinRam()) {
-		return false
+	return ids
+}
+
+func (store *InMemoryLaptopStore) scanCandidates(filter *pb.Filter) []string {
+	ids := make([]string, 0, len(store.data))
+	for id := range store.data {
+		ids = append(ids, id)
 	}
 
-	return true
+	return ids
 }
 
 func toBit(memory *pb.Memory) uint64 {
@@ -127,10 +235,9 @@ func toBit(memory *pb.Memory) uint64 {
 }
 
 func deepCopy(laptop *pb.Laptop) (*pb.Laptop, error) {
-	other := &pb.Laptop{}
-	err := copier.Copy(other, laptop)
-	if err != nil {
-		return nil, fmt.Errorf("cannot copy laptop data: %w", err)
+	other, ok := proto.Clone(laptop).(*pb.Laptop)
+	if !ok {
+		return nil, fmt.Errorf("cannot copy laptop data")
 	}
 
 	return other, nil
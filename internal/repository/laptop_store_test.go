@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/sample"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+var indexedFilters = []*pb.Filter{
+	{MaxPriceUsd: 1600},
+	{MinCpuCores: 7, MinRam: &pb.Memory{Value: 48, Unit: pb.Memory_GIGABYTE}},
+	{MinCpuGhz: 3.3, Brands: []string{"Dell"}},
+	{PriceUsd: &pb.DoubleRange{Min: proto.Float64(2000), Max: proto.Float64(2100)}},
+	{Ram: &pb.MemoryRange{Max: &pb.Memory{Value: 8, Unit: pb.Memory_GIGABYTE}}},
+	{CpuCores: &pb.UInt32Range{Min: proto.Uint32(3), Max: proto.Uint32(3)}},
+}
+
+func newTestLaptopStore(t testing.TB, n int) *InMemoryLaptopStore {
+	store := NewInMemoryLaptopStore()
+	for i := 0; i < n; i++ {
+		err := store.Save(sample.NewLaptop())
+		require.NoError(t, err)
+	}
+
+	return store
+}
+
+func searchIDs(t testing.TB, store *InMemoryLaptopStore, filter *pb.Filter, candidates func(*pb.Filter) []string) []string {
+	ids := []string{}
+	err := store.search(context.Background(), filter, func(laptop *pb.Laptop) error {
+		ids = append(ids, laptop.GetId())
+		return nil
+	}, candidates)
+	require.NoError(t, err)
+
+	sort.Strings(ids)
+	return ids
+}
+
+func TestInMemoryLaptopStoreIndexedSearch(t *testing.T) {
+	t.Parallel()
+
+	store := newTestLaptopStore(t, 500)
+
+	laptops, err := store.List(context.Background(), "", 100)
+	require.NoError(t, err)
+
+	for i, laptop := range laptops {
+		if i%2 == 0 {
+			laptop.PriceUsd = 1550
+			laptop.Ram = &pb.Memory{Value: 4, Unit: pb.Memory_GIGABYTE}
+			require.NoError(t, store.Update(laptop))
+		} else {
+			require.NoError(t, store.Delete(laptop.GetId()))
+		}
+	}
+
+	filters := append(indexedFilters, &pb.Filter{Brands: []string{"Apple"}}, nil)
+	for _, filter := range filters {
+		expected := searchIDs(t, store, filter, store.scanCandidates)
+		require.Equal(t, expected, searchIDs(t, store, filter, store.indexedCandidates), "filter: %v", filter)
+	}
+}
+
+func benchmarkSearch(b *testing.B, indexed bool) {
+	store := newTestLaptopStore(b, 20000)
+
+	candidates := store.scanCandidates
+	if indexed {
+		candidates = store.indexedCandidates
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter := indexedFilters[i%len(indexedFilters)]
+		err := store.search(context.Background(), filter, func(laptop *pb.Laptop) error {
+			return nil
+		}, candidates)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkSearchIndexed(b *testing.B) {
+	benchmarkSearch(b, true)
+}
+
+func BenchmarkSearchScan(b *testing.B) {
+	benchmarkSearch(b, false)
+}
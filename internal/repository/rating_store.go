@@ -1,28 +1,113 @@
 package repository
 
-import "sync"
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
 
 type RatingStore interface {
-	Add(laptopID string, score float64) (*Rating, error)
+	// Add records the score a user gives to a laptop, replacing the user's
+	// previous score of the same laptop.
+	Add(laptopID string, username string, score float64) (*Rating, error)
+	Find(laptopID string) (*Rating, error)
+	// Top calls found with the rated laptops from the highest average score
+	// to the lowest, breaking ties by laptop ID.
+	Top(ctx context.Context, found func(laptopID string, rating *Rating) error) error
+	// Delete forgets the rating of a laptop and every score it was given.
+	Delete(laptopID string) error
 }
 
 type Rating struct {
-	Count uint32
-	Sum   float64
+	Count      uint32
+	Sum        float64
+	SumSquares float64
+	// Histogram counts the scores by their integer part.
+	Histogram map[int]uint32
+}
+
+func (rating *Rating) Average() float64 {
+	if rating == nil || rating.Count == 0 {
+		return 0
+	}
+
+	return rating.Sum / float64(rating.Count)
+}
+
+// StdDev returns the population standard deviation of the scores.
+func (rating *Rating) StdDev() float64 {
+	if rating == nil || rating.Count == 0 {
+		return 0
+	}
+
+	average := rating.Average()
+	variance := rating.SumSquares/float64(rating.Count) - average*average
+	if variance < 0 {
+		// rounding errors of scores removed by re-rating
+		return 0
+	}
+
+	return math.Sqrt(variance)
+}
+
+func (rating *Rating) add(score float64) {
+	if rating.Histogram == nil {
+		rating.Histogram = make(map[int]uint32)
+	}
+
+	rating.Count++
+	rating.Sum += score
+	rating.SumSquares += score * score
+	rating.Histogram[ScoreBucket(score)]++
+}
+
+func (rating *Rating) remove(score float64) {
+	rating.Count--
+	rating.Sum -= score
+	rating.SumSquares -= score * score
+
+	bucket := ScoreBucket(score)
+	rating.Histogram[bucket]--
+	if rating.Histogram[bucket] == 0 {
+		delete(rating.Histogram, bucket)
+	}
+}
+
+func (rating *Rating) clone() *Rating {
+	other := &Rating{
+		Count:      rating.Count,
+		Sum:        rating.Sum,
+		SumSquares: rating.SumSquares,
+		Histogram:  make(map[int]uint32, len(rating.Histogram)),
+	}
+
+	for bucket, count := range rating.Histogram {
+		other.Histogram[bucket] = count
+	}
+
+	return other
+}
+
+// ScoreBucket returns the histogram bucket of a score.
+func ScoreBucket(score float64) int {
+	return int(math.Floor(score))
 }
 
 type InMemoryRatingStore struct {
 	mutex  sync.RWMutex
 	rating map[string]*Rating
+	scores map[string]map[string]float64
 }
 
 func NewInMemoryRatingStore() *InMemoryRatingStore {
 	return &InMemoryRatingStore{
 		rating: make(map[string]*Rating),
+		scores: make(map[string]map[string]float64),
 	}
 }
 
-func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating, error) {
+func (store *InMemoryRatingStore) Add(laptopID string, username string, score float64) (*Rating, error) {
 	store.mutex.Lock()
 	defer store.mutex.Unlock()
 
@@ -30,10 +115,77 @@ func (store *InMemoryRatingStore) Add(laptopID string, score float64) (*Rating,
 	if !found {
 		rating = &Rating{}
 		store.rating[laptopID] = rating
+		store.scores[laptopID] = make(map[string]float64)
 	}
 
-	rating.Count++
-	rating.Sum += score
+	if previous, rated := store.scores[laptopID][username]; rated {
+		rating.remove(previous)
+	}
+
+	rating.add(score)
+	store.scores[laptopID][username] = score
+
+	return rating.clone(), nil
+}
+
+func (store *InMemoryRatingStore) Find(laptopID string) (*Rating, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	rating, found := store.rating[laptopID]
+	if !found {
+		return nil, nil
+	}
+
+	return rating.clone(), nil
+}
+
+func (store *InMemoryRatingStore) Delete(laptopID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.rating, laptopID)
+	delete(store.scores, laptopID)
+	return nil
+}
+
+func (store *InMemoryRatingStore) Top(ctx context.Context, found func(laptopID string, rating *Rating) error) error {
+	laptopIDs, ratings := store.snapshot()
+
+	sort.Slice(laptopIDs, func(i, j int) bool {
+		average1 := ratings[laptopIDs[i]].Average()
+		average2 := ratings[laptopIDs[j]].Average()
+		if average1 != average2 {
+			return average1 > average2
+		}
+
+		return laptopIDs[i] < laptopIDs[j]
+	})
+
+	for _, laptopID := range laptopIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := found(laptopID, ratings[laptopID])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *InMemoryRatingStore) snapshot() ([]string, map[string]*Rating) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	laptopIDs := make([]string, 0, len(store.rating))
+	ratings := make(map[string]*Rating, len(store.rating))
+	for laptopID, rating := range store.rating {
+		laptopIDs = append(laptopIDs, laptopID)
+		ratings[laptopID] = rating.clone()
+	}
 
-	return rating, nil
+	return laptopIDs, ratings
 }
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+type ReviewStore interface {
+	Save(review *pb.Review) error
+	Find(id string) (*pb.Review, error)
+	SetHidden(id string, hidden bool) (*pb.Review, error)
+	// List returns up to limit reviews of a laptop, newest first, starting
+	// right after the cursor, or from the newest one when it is nil. Hidden
+	// reviews are left out unless includeHidden is set.
+	List(ctx context.Context, laptopID string, after *ReviewCursor, limit int, includeHidden bool) ([]*pb.Review, error)
+	// DeleteLaptop deletes every review of a laptop.
+	DeleteLaptop(laptopID string) error
+}
+
+// ReviewCursor is the position of a review in the order reviews are listed
+// in. Reviews created at the same time are ordered by ID.
+type ReviewCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// NewReviewCursor returns the position of the review.
+func NewReviewCursor(review *pb.Review) *ReviewCursor {
+	return &ReviewCursor{
+		CreatedAt: review.GetCreatedAt().AsTime(),
+		ID:        review.GetId(),
+	}
+}
+
+const reviewTimeSize = 8
+
+// key sorts the same way as the reviews: by creation time, with the sign bit
+// flipped so that times before 1970 come first, and then by ID.
+func (cursor *ReviewCursor) key() []byte {
+	key := make([]byte, reviewTimeSize, reviewTimeSize+len(cursor.ID))
+	binary.BigEndian.PutUint64(key, uint64(cursor.CreatedAt.UnixNano())^1<<63)
+
+	return append(key, cursor.ID...)
+}
+
+func reviewKey(review *pb.Review) []byte {
+	return NewReviewCursor(review).key()
+}
+
+type InMemoryReviewStore struct {
+	mutex sync.RWMutex
+	data  map[string]*pb.Review
+}
+
+func NewInMemoryReviewStore() *InMemoryReviewStore {
+	return &InMemoryReviewStore{
+		data: make(map[string]*pb.Review),
+	}
+}
+
+func (store *InMemoryReviewStore) Save(review *pb.Review) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.data[review.GetId()] != nil {
+		return ErrAlreadyExists
+	}
+
+	store.data[review.GetId()] = proto.Clone(review).(*pb.Review)
+	return nil
+}
+
+func (store *InMemoryReviewStore) Find(id string) (*pb.Review, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	review := store.data[id]
+	if review == nil {
+		return nil, nil
+	}
+
+	return proto.Clone(review).(*pb.Review), nil
+}
+
+func (store *InMemoryReviewStore) SetHidden(id string, hidden bool) (*pb.Review, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	review := store.data[id]
+	if review == nil {
+		return nil, ErrNotFound
+	}
+
+	review.Hidden = hidden
+	return proto.Clone(review).(*pb.Review), nil
+}
+
+func (store *InMemoryReviewStore) List(ctx context.Context, laptopID string, after *ReviewCursor, limit int, includeHidden bool) ([]*pb.Review, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	var afterKey []byte
+	if after != nil {
+		afterKey = after.key()
+	}
+
+	found := []*pb.Review{}
+	for _, review := range store.data {
+		if review.GetLaptopId() != laptopID || (review.GetHidden() && !includeHidden) {
+			continue
+		}
+
+		if afterKey != nil && bytes.Compare(reviewKey(review), afterKey) >= 0 {
+			continue
+		}
+
+		found = append(found, review)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return bytes.Compare(reviewKey(found[i]), reviewKey(found[j])) > 0
+	})
+
+	if len(found) > limit {
+		found = found[:limit]
+	}
+
+	reviews := make([]*pb.Review, 0, len(found))
+	for _, review := range found {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, proto.Clone(review).(*pb.Review))
+	}
+
+	return reviews, nil
+}
+
+func (store *InMemoryReviewStore) DeleteLaptop(laptopID string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	for id, review := range store.data {
+		if review.GetLaptopId() == laptopID {
+			delete(store.data, id)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalReview(data []byte) (*pb.Review, error) {
+	review := &pb.Review{}
+
+	err := proto.Unmarshal(data, review)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal review: %w", err)
+	}
+
+	return review, nil
+}
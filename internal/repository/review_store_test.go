@@ -0,0 +1,147 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestReviewStore(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		newStore func(t *testing.T) repository.ReviewStore
+	}{
+		{
+			name: "memory",
+			newStore: func(t *testing.T) repository.ReviewStore {
+				return repository.NewInMemoryReviewStore()
+			},
+		},
+		{
+			name: "bolt",
+			newStore: func(t *testing.T) repository.ReviewStore {
+				db, err := repository.OpenBoltDB(t.TempDir())
+				require.NoError(t, err)
+				t.Cleanup(func() { db.Close() })
+
+				store, err := repository.NewBoltReviewStore(db)
+				require.NoError(t, err)
+				return store
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			createdAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			at := func(seconds int) *timestamppb.Timestamp {
+				return timestamppb.New(createdAt.Add(time.Duration(seconds) * time.Second))
+			}
+
+			// the IDs are not in the order the reviews were written
+			reviews := []*pb.Review{
+				{Id: "c", LaptopId: "laptop-1", Author: "user1", Title: "good", Text: "fast", CreatedAt: at(0)},
+				{Id: "b", LaptopId: "laptop-1", Author: "user2", Title: "bad", Text: "slow", CreatedAt: at(1)},
+				{Id: "a", LaptopId: "laptop-1", Author: "user3", Title: "fine", Text: "ok", CreatedAt: at(2)},
+				{Id: "e", LaptopId: "laptop-1", Author: "user4", Title: "same", Text: "time", CreatedAt: at(2)},
+				{Id: "d", LaptopId: "laptop-2", Author: "user1", Title: "other", Text: "laptop", CreatedAt: at(3)},
+			}
+			for _, review := range reviews {
+				err := store.Save(review)
+				require.NoError(t, err)
+			}
+
+			err := store.Save(reviews[0])
+			require.ErrorIs(t, err, repository.ErrAlreadyExists)
+
+			found, err := store.Find("b")
+			require.NoError(t, err)
+			require.True(t, proto.Equal(reviews[1], found))
+
+			found, err = store.Find("z")
+			require.NoError(t, err)
+			require.Nil(t, found)
+
+			hidden, err := store.SetHidden("b", true)
+			require.NoError(t, err)
+			require.True(t, hidden.GetHidden())
+
+			_, err = store.SetHidden("z", true)
+			require.ErrorIs(t, err, repository.ErrNotFound)
+
+			found, err = store.Find("b")
+			require.NoError(t, err)
+			require.True(t, found.GetHidden())
+
+			page, err := store.List(context.Background(), "laptop-1", nil, 10, false)
+			require.NoError(t, err)
+			require.Equal(t, []string{"e", "a", "c"}, reviewIDs(page))
+
+			page, err = store.List(context.Background(), "laptop-1", nil, 10, true)
+			require.NoError(t, err)
+			require.Equal(t, []string{"e", "a", "b", "c"}, reviewIDs(page))
+
+			page, err = store.List(context.Background(), "laptop-1", repository.NewReviewCursor(page[0]), 1, false)
+			require.NoError(t, err)
+			require.Equal(t, []string{"a"}, reviewIDs(page))
+
+			page, err = store.List(context.Background(), "laptop-1", repository.NewReviewCursor(page[0]), 10, false)
+			require.NoError(t, err)
+			require.Equal(t, []string{"c"}, reviewIDs(page))
+
+			_, err = store.SetHidden("b", false)
+			require.NoError(t, err)
+
+			page, err = store.List(context.Background(), "laptop-1", repository.NewReviewCursor(reviews[2]), 2, false)
+			require.NoError(t, err)
+			require.Equal(t, []string{"b", "c"}, reviewIDs(page))
+
+			page, err = store.List(context.Background(), "laptop-3", nil, 10, false)
+			require.NoError(t, err)
+			require.Empty(t, page)
+
+			err = store.DeleteLaptop("laptop-1")
+			require.NoError(t, err)
+			err = store.DeleteLaptop("laptop-3")
+			require.NoError(t, err)
+
+			page, err = store.List(context.Background(), "laptop-1", nil, 10, true)
+			require.NoError(t, err)
+			require.Empty(t, page)
+
+			found, err = store.Find("a")
+			require.NoError(t, err)
+			require.Nil(t, found)
+
+			found, err = store.Find("d")
+			require.NoError(t, err)
+			require.NotNil(t, found)
+
+			// the IDs of deleted reviews can be saved again
+			err = store.Save(reviews[0])
+			require.NoError(t, err)
+		})
+	}
+}
+
+func reviewIDs(reviews []*pb.Review) []string {
+	ids := []string{}
+	for _, review := range reviews {
+		ids = append(ids, review.GetId())
+	}
+
+	return ids
+}
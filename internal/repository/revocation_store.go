@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+const DEFAULT_REVOCATION_PRUNE_INTERVAL = 10 * time.Minute
+
+// RevocationStore remembers revoked token IDs until the tokens expire, after
+// which they are rejected anyway.
+type RevocationStore interface {
+	Revoke(id string, expiresAt time.Time) error
+	// RevokeOnce revokes the ID unless it is revoked already, and tells
+	// whether it did so.
+	RevokeOnce(id string, expiresAt time.Time) (bool, error)
+	IsRevoked(id string) (bool, error)
+}
+
+// revocationPruning forgets the expired revocations every interval, with a
+// timer that only runs while there may be revocations to forget.
+type revocationPruning struct {
+	interval time.Duration
+	timer    *time.Timer
+}
+
+type RevocationStoreOption func(*revocationPruning)
+
+// WithRevocationPruneInterval sets how often the expired revocations are
+// forgotten.
+func WithRevocationPruneInterval(interval time.Duration) RevocationStoreOption {
+	return func(pruning *revocationPruning) {
+		pruning.interval = interval
+	}
+}
+
+func newRevocationPruning(opts []RevocationStoreOption) revocationPruning {
+	pruning := revocationPruning{
+		interval: DEFAULT_REVOCATION_PRUNE_INTERVAL,
+	}
+
+	for _, opt := range opts {
+		opt(&pruning)
+	}
+
+	return pruning
+}
+
+type InMemoryRevocationStore struct {
+	mutex   sync.RWMutex
+	revoked map[string]time.Time
+	pruning revocationPruning
+}
+
+func NewInMemoryRevocationStore(opts ...RevocationStoreOption) *InMemoryRevocationStore {
+	return &InMemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+		pruning: newRevocationPruning(opts),
+	}
+}
+
+func (store *InMemoryRevocationStore) Revoke(id string, expiresAt time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.revoke(id, expiresAt)
+	return nil
+}
+
+func (store *InMemoryRevocationStore) RevokeOnce(id string, expiresAt time.Time) (bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.revoked[id]; ok {
+		return false, nil
+	}
+
+	store.revoke(id, expiresAt)
+	return true, nil
+}
+
+func (store *InMemoryRevocationStore) IsRevoked(id string) (bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	_, ok := store.revoked[id]
+	return ok, nil
+}
+
+func (store *InMemoryRevocationStore) revoke(id string, expiresAt time.Time) {
+	if previous, ok := store.revoked[id]; !ok || previous.Before(expiresAt) {
+		store.revoked[id] = expiresAt
+	}
+
+	if store.pruning.timer == nil {
+		store.pruning.timer = time.AfterFunc(store.pruning.interval, store.prune)
+	}
+}
+
+func (store *InMemoryRevocationStore) prune() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range store.revoked {
+		if expiresAt.Before(now) {
+			delete(store.revoked, id)
+		}
+	}
+
+	if len(store.revoked) == 0 {
+		store.pruning.timer = nil
+		return
+	}
+
+	store.pruning.timer.Reset(store.pruning.interval)
+}
@@ -0,0 +1,97 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationStore(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		newStore func(t *testing.T) repository.RevocationStore
+	}{
+		{
+			name: "memory",
+			newStore: func(t *testing.T) repository.RevocationStore {
+				return repository.NewInMemoryRevocationStore(repository.WithRevocationPruneInterval(20 * time.Millisecond))
+			},
+		},
+		{
+			name: "bolt",
+			newStore: func(t *testing.T) repository.RevocationStore {
+				db, err := repository.OpenBoltDB(t.TempDir())
+				require.NoError(t, err)
+				t.Cleanup(func() { db.Close() })
+
+				store, err := repository.NewBoltRevocationStore(db, repository.WithRevocationPruneInterval(20*time.Millisecond))
+				require.NoError(t, err)
+				return store
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			err := store.Revoke("expired", time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+
+			revoked, err := store.IsRevoked("expired")
+			require.NoError(t, err)
+			require.True(t, revoked)
+
+			err = store.Revoke("token", time.Now().Add(time.Hour))
+			require.NoError(t, err)
+
+			revoked, err = store.IsRevoked("token")
+			require.NoError(t, err)
+			require.True(t, revoked)
+
+			// the expired tokens are purged in the background
+			require.Eventually(t, func() bool {
+				revoked, err := store.IsRevoked("expired")
+				require.NoError(t, err)
+				return !revoked
+			}, time.Second, 10*time.Millisecond)
+
+			revoked, err = store.IsRevoked("token")
+			require.NoError(t, err)
+			require.True(t, revoked)
+
+			revoked, err = store.IsRevoked("other")
+			require.NoError(t, err)
+			require.False(t, revoked)
+
+			// only one of the concurrent calls revokes the token
+			results := make(chan bool, 10)
+			for i := 0; i < cap(results); i++ {
+				go func() {
+					revoked, err := store.RevokeOnce("once", time.Now().Add(time.Hour))
+					require.NoError(t, err)
+					results <- revoked
+				}()
+			}
+
+			revokedCount := 0
+			for i := 0; i < cap(results); i++ {
+				if <-results {
+					revokedCount++
+				}
+			}
+			require.Equal(t, 1, revokedCount)
+
+			revoked, err = store.RevokeOnce("token", time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			require.False(t, revoked)
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Secure    bool
+}
+
+// S3ImageStore keeps images in a bucket of an S3-compatible object storage,
+// so every server replica sees the same images. Each image is stored under
+// images/<id>/ next to an info.json object holding its ImageInfo, and an
+// empty laptops/<laptop id>/<id> object lists it under its laptop.
+type S3ImageStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3ImageStore(config S3Config) (*S3ImageStore, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure:       config.Secure,
+		Region:       config.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create s3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(context.Background(), config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check s3 bucket: %w", err)
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("s3 bucket %s doesn't exist", config.Bucket)
+	}
+
+	return &S3ImageStore{
+		client: client,
+		bucket: config.Bucket,
+	}, nil
+}
+
+func (store *S3ImageStore) Save(laptopID string, contentType string, imageData io.Reader) (string, error) {
+	imageType, ok := ImageExtension(contentType)
+	if !ok {
+		return "", fmt.Errorf("unsupported image content type %q", contentType)
+	}
+
+	imageID, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("cannot generate image id: %w", err)
+	}
+
+	info := &ImageInfo{
+		ID:          imageID.String(),
+		LaptopID:    laptopID,
+		Type:        imageType,
+		ContentType: contentType,
+		Path:        imageKey(imageID.String(), "original", imageType),
+		CreatedAt:   time.Now().UTC(),
+		Variants:    make(map[string]*ImageVariant),
+	}
+
+	info.Size, info.Checksum, err = store.putFile(info.Path, contentType, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	err = store.putInfo(info)
+	if err != nil {
+		store.removeObject(info.Path)
+		return "", err
+	}
+
+	err = store.putObject(laptopKey(laptopID, info.ID), "application/octet-stream", bytes.NewReader(nil), 0)
+	if err != nil {
+		store.removeObject(infoKey(info.ID))
+		store.removeObject(info.Path)
+		return "", err
+	}
+
+	return info.ID, nil
+}
+
+// SaveVariant stores a resized rendition next to the original image,
+// replacing any previous variant with the same name.
+func (store *S3ImageStore) SaveVariant(id string, variant ImageVariant, imageData io.Reader) error {
+	imageType, ok := ImageExtension(variant.ContentType)
+	if !ok {
+		return fmt.Errorf("unsupported image content type %q", variant.ContentType)
+	}
+
+	info, err := store.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return ErrNotFound
+	}
+
+	variant.Path = imageKey(id, variant.Name, imageType)
+	variant.Size, variant.Checksum, err = store.putFile(variant.Path, variant.ContentType, imageData)
+	if err != nil {
+		return err
+	}
+
+	previous, replaced := info.Variants[variant.Name]
+	info.Variants[variant.Name] = &variant
+
+	err = store.putInfo(info)
+	if err != nil {
+		return err
+	}
+
+	if replaced && previous.Path != variant.Path {
+		return store.removeObject(previous.Path)
+	}
+
+	return nil
+}
+
+func (store *S3ImageStore) Find(id string) (*ImageInfo, error) {
+	// image IDs become part of object keys, so anything else is unknown
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+
+	object, err := store.client.GetObject(context.Background(), store.bucket, infoKey(id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get image info: %w", err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		if isNoSuchKey(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read image info: %w", err)
+	}
+
+	info := &ImageInfo{}
+	err = json.Unmarshal(data, info)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode image info: %w", err)
+	}
+
+	if info.Variants == nil {
+		info.Variants = make(map[string]*ImageVariant)
+	}
+
+	return info, nil
+}
+
+// List returns the images of a laptop ordered by ID.
+func (store *S3ImageStore) List(laptopID string) ([]*ImageInfo, error) {
+	prefix := laptopKey(laptopID, "")
+	objects := store.client.ListObjects(context.Background(), store.bucket, minio.ListObjectsOptions{
+		Prefix: prefix,
+	})
+
+	images := []*ImageInfo{}
+	for object := range objects {
+		if object.Err != nil {
+			return nil, fmt.Errorf("cannot list images: %w", object.Err)
+		}
+
+		info, err := store.Find(strings.TrimPrefix(object.Key, prefix))
+		if err != nil {
+			return nil, err
+		}
+
+		if info != nil {
+			images = append(images, info)
+		}
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+
+	return images, nil
+}
+
+// Open returns the original image when variant is empty, or the named
+// resized rendition otherwise.
+func (store *S3ImageStore) Open(id string, variant string) (io.ReadCloser, error) {
+	info, err := store.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, ErrNotFound
+	}
+
+	key := info.Path
+	if variant != "" {
+		imageVariant, ok := info.Variants[variant]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		key = imageVariant.Path
+	}
+
+	object, err := store.client.GetObject(context.Background(), store.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get image: %w", err)
+	}
+
+	return object, nil
+}
+
+func (store *S3ImageStore) Delete(id string) error {
+	info, err := store.Find(id)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return ErrNotFound
+	}
+
+	keys := []string{laptopKey(info.LaptopID, id), infoKey(id), info.Path}
+	for _, variant := range info.Variants {
+		keys = append(keys, variant.Path)
+	}
+
+	for _, key := range keys {
+		err := store.removeObject(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putFile spools data to a temporary file to learn its size and checksum,
+// which S3 needs before the upload starts, and then uploads it.
+func (store *S3ImageStore) putFile(key string, contentType string, data io.Reader) (int64, string, error) {
+	file, err := os.CreateTemp("", "s3-image-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot create image file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), data)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot write image to file: %w", err)
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot rewind image file: %w", err)
+	}
+
+	err = store.putObject(key, contentType, file, size)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (store *S3ImageStore) putInfo(info *ImageInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("cannot encode image info: %w", err)
+	}
+
+	return store.putObject(infoKey(info.ID), "application/json", bytes.NewReader(data), int64(len(data)))
+}
+
+func (store *S3ImageStore) putObject(key string, contentType string, data io.Reader, size int64) error {
+	_, err := store.client.PutObject(context.Background(), store.bucket, key, data, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		// images are small enough for a single request, and a plain payload
+		// keeps the store compatible with the simplest S3 implementations
+		DisableMultipart:     true,
+		DisableContentSha256: true,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put object %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (store *S3ImageStore) removeObject(key string) error {
+	err := store.client.RemoveObject(context.Background(), store.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("cannot remove object %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func imageKey(id string, name string, imageType string) string {
+	return path.Join("images", id, name+"."+imageType)
+}
+
+func infoKey(id string) string {
+	return path.Join("images", id, "info.json")
+}
+
+func laptopKey(laptopID string, id string) string {
+	return "laptops/" + laptopID + "/" + id
+}
+
+func isNoSuchKey(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
@@ -0,0 +1,115 @@
+package repository_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestS3ImageStore(t *testing.T) (*repository.S3ImageStore, *fakeS3) {
+	s3, endpoint := startFakeS3(t, "images")
+
+	store, err := repository.NewS3ImageStore(repository.S3Config{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    "images",
+		AccessKey: fakeS3AccessKey,
+		SecretKey: fakeS3SecretKey,
+	})
+	require.NoError(t, err)
+
+	return store, s3
+}
+
+func TestS3ImageStore(t *testing.T) {
+	t.Parallel()
+
+	store, s3 := newTestS3ImageStore(t)
+
+	imageData := bytes.Repeat([]byte("laptop"), 1<<16)
+	imageID, err := store.Save("laptop-1", "image/jpeg", bytes.NewReader(imageData))
+	require.NoError(t, err)
+
+	otherID, err := store.Save("laptop-2", "image/png", bytes.NewReader([]byte("other photo")))
+	require.NoError(t, err)
+
+	variant := repository.ImageVariant{Name: "thumbnail", ContentType: "image/jpeg", Width: 2, Height: 1}
+	err = store.SaveVariant(imageID, variant, bytes.NewReader([]byte("small photo")))
+	require.NoError(t, err)
+
+	info, err := store.Find(imageID)
+	require.NoError(t, err)
+	require.Equal(t, "laptop-1", info.LaptopID)
+	require.Equal(t, "image/jpeg", info.ContentType)
+	require.Equal(t, int64(len(imageData)), info.Size)
+	require.Len(t, info.Checksum, 64)
+	require.Equal(t, 2, info.Variants["thumbnail"].Width)
+	require.Equal(t, int64(len("small photo")), info.Variants["thumbnail"].Size)
+
+	images, err := store.List("laptop-1")
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	require.Equal(t, imageID, images[0].ID)
+
+	requireS3Object(t, store, imageID, "", imageData)
+	requireS3Object(t, store, imageID, "thumbnail", []byte("small photo"))
+
+	_, err = store.Open(imageID, "huge")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	err = store.Delete(imageID)
+	require.NoError(t, err)
+
+	info, err = store.Find(imageID)
+	require.NoError(t, err)
+	require.Nil(t, info)
+
+	err = store.Delete(imageID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	images, err = store.List("laptop-1")
+	require.NoError(t, err)
+	require.Empty(t, images)
+
+	err = store.Delete(otherID)
+	require.NoError(t, err)
+	require.Empty(t, s3.keys())
+}
+
+func TestS3ImageStoreInvalidID(t *testing.T) {
+	t.Parallel()
+
+	store, _ := newTestS3ImageStore(t)
+
+	info, err := store.Find("../laptops/laptop-1")
+	require.NoError(t, err)
+	require.Nil(t, info)
+}
+
+func TestS3ImageStoreWrongCredentials(t *testing.T) {
+	t.Parallel()
+
+	_, endpoint := startFakeS3(t, "images")
+
+	_, err := repository.NewS3ImageStore(repository.S3Config{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    "images",
+		AccessKey: "someone-else",
+		SecretKey: fakeS3SecretKey,
+	})
+	require.Error(t, err)
+}
+
+func requireS3Object(t *testing.T, store *repository.S3ImageStore, imageID string, variant string, expected []byte) {
+	reader, err := store.Open(imageID, variant)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+}
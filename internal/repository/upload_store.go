@@ -0,0 +1,480 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DEFAULT_UPLOAD_TTL = time.Hour
+
+// an upload is staged in .upload-<id>.part and described by .upload-<id>.json
+const (
+	uploadFilePrefix = ".upload-"
+	uploadDataExt    = ".part"
+	uploadRecordExt  = ".json"
+)
+
+var (
+	ErrOffsetMismatch   = errors.New("offset does not match the committed offset")
+	ErrUploadInProgress = errors.New("upload is already in progress")
+)
+
+// UploadStore keeps the chunks of images that are still being uploaded, so
+// that an interrupted upload can continue from its committed offset. Chunks
+// are only appended, and the upload only finished, by whoever acquired it.
+type UploadStore interface {
+	Create(laptopID string, contentType string, checksum string) (*UploadInfo, error)
+	Find(id string) (*UploadInfo, error)
+	Acquire(id string) (*UploadInfo, error)
+	Release(id string) error
+	Append(id string, offset int64, chunk []byte) (int64, error)
+	Open(id string) (io.ReadCloser, error)
+	Delete(id string) error
+}
+
+type UploadInfo struct {
+	ID       string
+	LaptopID string
+	// ContentType is the image type declared by the client, if any.
+	ContentType string
+	Checksum    string
+	Offset      int64
+	// Path is the file holding the committed bytes.
+	Path string
+}
+
+// uploadRecord is the part of an upload written next to its staged file, so
+// that the upload survives a restart.
+type uploadRecord struct {
+	ID          string `json:"id"`
+	LaptopID    string `json:"laptop_id"`
+	ContentType string `json:"content_type,omitempty"`
+	Checksum    string `json:"checksum,omitempty"`
+}
+
+type upload struct {
+	info UploadInfo
+	// file is open while the upload is acquired.
+	file         *os.File
+	lastActivity time.Time
+}
+
+// DiskUploadStore writes the chunks of each upload to a hidden file in the
+// upload folder as they arrive, next to a record of the upload, so uploads
+// continue after a restart. Uploads that are not acquired and see no activity
+// for the TTL are deleted, so abandoned ones don't fill the disk.
+type DiskUploadStore struct {
+	mutex        sync.Mutex
+	uploadFolder string
+	uploads      map[string]*upload
+	ttl          time.Duration
+	expiry       *time.Timer
+}
+
+type DiskUploadStoreOption func(*DiskUploadStore)
+
+// WithUploadTTL sets how long an upload is kept after its last activity.
+func WithUploadTTL(ttl time.Duration) DiskUploadStoreOption {
+	return func(store *DiskUploadStore) {
+		store.ttl = ttl
+	}
+}
+
+func NewDiskUploadStore(uploadFolder string, opts ...DiskUploadStoreOption) *DiskUploadStore {
+	store := &DiskUploadStore{
+		uploadFolder: uploadFolder,
+		uploads:      make(map[string]*upload),
+		ttl:          DEFAULT_UPLOAD_TTL,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	err := store.load()
+	if err != nil {
+		log.Printf("cannot restore uploads: %v", err)
+	}
+
+	return store
+}
+
+func (store *DiskUploadStore) Create(laptopID string, contentType string, checksum string) (*UploadInfo, error) {
+	uploadID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate upload id: %w", err)
+	}
+
+	upload := &upload{
+		info: UploadInfo{
+			ID:          uploadID.String(),
+			LaptopID:    laptopID,
+			ContentType: contentType,
+			Checksum:    checksum,
+			Path:        store.uploadPath(uploadID.String(), uploadDataExt),
+		},
+		lastActivity: time.Now(),
+	}
+
+	file, err := os.OpenFile(upload.info.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create upload file: %w", err)
+	}
+
+	err = file.Close()
+	if err == nil {
+		err = store.saveRecord(&upload.info)
+	}
+
+	if err != nil {
+		os.Remove(upload.info.Path)
+		return nil, fmt.Errorf("cannot create upload file: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.add(upload)
+
+	info := upload.info
+	return &info, nil
+}
+
+// add keeps track of the upload. It must be called with the mutex held.
+func (store *DiskUploadStore) add(upload *upload) {
+	store.uploads[upload.info.ID] = upload
+
+	// the timer only runs while there are uploads to expire
+	if store.expiry == nil {
+		store.expiry = time.AfterFunc(store.ttl, store.expire)
+	}
+}
+
+func (store *DiskUploadStore) Find(id string) (*UploadInfo, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return nil, nil
+	}
+
+	info := upload.info
+	return &info, nil
+}
+
+// Acquire opens the upload for appending, and fails with ErrUploadInProgress
+// while someone else holds it.
+func (store *DiskUploadStore) Acquire(id string) (*UploadInfo, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if upload.file != nil {
+		return nil, ErrUploadInProgress
+	}
+
+	file, err := os.OpenFile(upload.info.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open upload file: %w", err)
+	}
+
+	upload.file = file
+	upload.lastActivity = time.Now()
+
+	info := upload.info
+	return &info, nil
+}
+
+// Release closes the upload, so it can be acquired again. Releasing a deleted
+// upload does nothing.
+func (store *DiskUploadStore) Release(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok || upload.file == nil {
+		return nil
+	}
+
+	err := upload.file.Close()
+	upload.file = nil
+	upload.lastActivity = time.Now()
+	if err != nil {
+		return fmt.Errorf("cannot close upload file: %w", err)
+	}
+
+	return nil
+}
+
+// Append writes a chunk at offset, which must be the current end of the
+// acquired upload, and returns the new committed offset.
+func (store *DiskUploadStore) Append(id string, offset int64, chunk []byte) (int64, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	if upload.file == nil {
+		return upload.info.Offset, fmt.Errorf("upload %s is not acquired", id)
+	}
+
+	if offset != upload.info.Offset {
+		return upload.info.Offset, ErrOffsetMismatch
+	}
+
+	n, err := upload.file.WriteAt(chunk, offset)
+	if err != nil {
+		// the file keeps only the committed bytes
+		upload.file.Truncate(offset)
+		return upload.info.Offset, fmt.Errorf("cannot write chunk data: %w", err)
+	}
+
+	upload.info.Offset += int64(n)
+	upload.lastActivity = time.Now()
+	return upload.info.Offset, nil
+}
+
+// Open returns a reader over the committed bytes of the upload.
+func (store *DiskUploadStore) Open(id string) (io.ReadCloser, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	file, err := os.Open(upload.info.Path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open upload file: %w", err)
+	}
+
+	return &limitedFile{
+		Reader: io.LimitReader(file, upload.info.Offset),
+		file:   file,
+	}, nil
+}
+
+func (store *DiskUploadStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	upload, ok := store.uploads[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	return store.remove(upload)
+}
+
+// remove deletes the upload and its file. It must be called with the mutex
+// held.
+func (store *DiskUploadStore) remove(upload *upload) error {
+	delete(store.uploads, upload.info.ID)
+
+	if upload.file != nil {
+		upload.file.Close()
+	}
+
+	// without its record, a staged file left behind is deleted on restart
+	err := os.Remove(store.uploadPath(upload.info.ID, uploadRecordExt))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot delete upload record: %w", err)
+	}
+
+	err = os.Remove(upload.info.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot delete upload file: %w", err)
+	}
+
+	return nil
+}
+
+func (store *DiskUploadStore) uploadPath(id string, ext string) string {
+	return filepath.Join(store.uploadFolder, uploadFilePrefix+id+ext)
+}
+
+// saveRecord atomically writes the record of the upload next to its staged
+// file.
+func (store *DiskUploadStore) saveRecord(info *UploadInfo) error {
+	data, err := json.Marshal(uploadRecord{
+		ID:          info.ID,
+		LaptopID:    info.LaptopID,
+		ContentType: info.ContentType,
+		Checksum:    info.Checksum,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode upload record: %w", err)
+	}
+
+	file, err := os.CreateTemp(store.uploadFolder, uploadFilePrefix+"*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create upload record: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot write upload record: %w", err)
+	}
+
+	err = os.Rename(file.Name(), store.uploadPath(info.ID, uploadRecordExt))
+	if err != nil {
+		return fmt.Errorf("cannot move upload record into place: %w", err)
+	}
+
+	return nil
+}
+
+// load restores the uploads recorded in the upload folder by a previous run.
+// Their committed offset is the size of the staged file, and they expire a TTL
+// after the store is created. Staged files without a record are deleted.
+func (store *DiskUploadStore) load() error {
+	entries, err := os.ReadDir(store.uploadFolder)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot read upload folder: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isUploadFile(name) {
+			continue
+		}
+
+		id := strings.TrimPrefix(name, uploadFilePrefix)
+		if id, ok := strings.CutSuffix(id, uploadDataExt); ok {
+			if !fileExists(store.uploadPath(id, uploadRecordExt)) {
+				os.Remove(filepath.Join(store.uploadFolder, name))
+			}
+			continue
+		}
+
+		err := store.restore(strings.TrimSuffix(id, uploadRecordExt))
+		if err != nil {
+			log.Printf("cannot restore upload %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// restore adds the upload of a record, or deletes the record when the staged
+// file is gone.
+func (store *DiskUploadStore) restore(id string) error {
+	recordPath := store.uploadPath(id, uploadRecordExt)
+
+	data, err := os.ReadFile(recordPath)
+	if err != nil {
+		return fmt.Errorf("cannot read upload record: %w", err)
+	}
+
+	record := uploadRecord{}
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return fmt.Errorf("cannot decode upload record: %w", err)
+	}
+
+	if record.ID != id {
+		return fmt.Errorf("upload record has id %q", record.ID)
+	}
+
+	dataPath := store.uploadPath(id, uploadDataExt)
+	stat, err := os.Stat(dataPath)
+	if os.IsNotExist(err) {
+		os.Remove(recordPath)
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot read upload file: %w", err)
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.add(&upload{
+		info: UploadInfo{
+			ID:          record.ID,
+			LaptopID:    record.LaptopID,
+			ContentType: record.ContentType,
+			Checksum:    record.Checksum,
+			Offset:      stat.Size(),
+			Path:        dataPath,
+		},
+		lastActivity: time.Now(),
+	})
+
+	return nil
+}
+
+// isUploadFile tells whether the file is the staged file or the record of an
+// upload, which the upload store deletes once the upload is done or expired.
+func isUploadFile(name string) bool {
+	return strings.HasPrefix(name, uploadFilePrefix) &&
+		(strings.HasSuffix(name, uploadDataExt) || strings.HasSuffix(name, uploadRecordExt))
+}
+
+// expire deletes the uploads that expired, and checks again a TTL later while
+// any upload is left.
+func (store *DiskUploadStore) expire() {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := time.Now()
+	for _, upload := range store.uploads {
+		if upload.file != nil || now.Sub(upload.lastActivity) < store.ttl {
+			continue
+		}
+
+		err := store.remove(upload)
+		if err != nil {
+			log.Printf("cannot delete expired upload %s: %v", upload.info.ID, err)
+		}
+	}
+
+	if len(store.uploads) == 0 {
+		store.expiry = nil
+		return
+	}
+
+	store.expiry.Reset(store.ttl)
+}
+
+type limitedFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *limitedFile) Close() error {
+	return f.file.Close()
+}
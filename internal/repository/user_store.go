@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+	"sort"
 	"sync"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
@@ -9,6 +11,11 @@ import (
 type UserStore interface {
 	Save(user *entity.User) error
 	Find(username string) (*entity.User, error)
+	Update(user *entity.User) error
+	Delete(username string) error
+	// List returns up to limit users ordered by username, starting right after
+	// afterUsername.
+	List(ctx context.Context, afterUsername string, limit int) ([]*entity.User, error)
 }
 
 type InMemoryUserStore struct {
@@ -45,3 +52,55 @@ func (store *InMemoryUserStore) Find(username string) (*entity.User, error) {
 
 	return user.Clone(), nil
 }
+
+func (store *InMemoryUserStore) Update(user *entity.User) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.users[user.Username]; !ok {
+		return ErrNotFound
+	}
+
+	store.users[user.Username] = user.Clone()
+	return nil
+}
+
+func (store *InMemoryUserStore) Delete(username string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.users[username]; !ok {
+		return ErrNotFound
+	}
+
+	delete(store.users, username)
+	return nil
+}
+
+func (store *InMemoryUserStore) List(ctx context.Context, afterUsername string, limit int) ([]*entity.User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	usernames := make([]string, 0, len(store.users))
+	for username := range store.users {
+		if username > afterUsername {
+			usernames = append(usernames, username)
+		}
+	}
+	sort.Strings(usernames)
+
+	if len(usernames) > limit {
+		usernames = usernames[:limit]
+	}
+
+	users := make([]*entity.User, 0, len(usernames))
+	for _, username := range usernames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		users = append(users, store.users[username].Clone())
+	}
+
+	return users, nil
+}
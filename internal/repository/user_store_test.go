@@ -0,0 +1,87 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStore(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		newStore func(t *testing.T) repository.UserStore
+	}{
+		{
+			name: "memory",
+			newStore: func(t *testing.T) repository.UserStore {
+				return repository.NewInMemoryUserStore()
+			},
+		},
+		{
+			name: "bolt",
+			newStore: func(t *testing.T) repository.UserStore {
+				db, err := repository.OpenBoltDB(t.TempDir())
+				require.NoError(t, err)
+				t.Cleanup(func() { db.Close() })
+
+				store, err := repository.NewBoltUserStore(db)
+				require.NoError(t, err)
+				return store
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := tc.newStore(t)
+
+			for _, username := range []string{"carol", "alice", "bob"} {
+				user := &entity.User{Username: username, HashedPassword: []byte("hash-" + username), Role: "user"}
+				err := store.Save(user)
+				require.NoError(t, err)
+			}
+
+			err := store.Save(&entity.User{Username: "alice"})
+			require.ErrorIs(t, err, repository.ErrAlreadyExists)
+
+			user, err := store.Find("alice")
+			require.NoError(t, err)
+			require.Equal(t, []byte("hash-alice"), user.HashedPassword)
+
+			user.Role = "admin"
+			err = store.Update(user)
+			require.NoError(t, err)
+
+			user, err = store.Find("alice")
+			require.NoError(t, err)
+			require.Equal(t, "admin", user.Role)
+
+			err = store.Update(&entity.User{Username: "dave"})
+			require.ErrorIs(t, err, repository.ErrNotFound)
+
+			users, err := store.List(context.Background(), "alice", 10)
+			require.NoError(t, err)
+			require.Len(t, users, 2)
+			require.Equal(t, "bob", users[0].Username)
+			require.Equal(t, "carol", users[1].Username)
+
+			err = store.Delete("bob")
+			require.NoError(t, err)
+
+			err = store.Delete("bob")
+			require.ErrorIs(t, err, repository.ErrNotFound)
+
+			user, err = store.Find("bob")
+			require.NoError(t, err)
+			require.Nil(t, user)
+		})
+	}
+}
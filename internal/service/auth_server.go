@@ -2,42 +2,366 @@ package service
 
 import (
 	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
 
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/utils"
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type AuthServer struct {
-	userStore  repository.UserStore
-	jwtManager *JWTManager
+	userStore    repository.UserStore
+	jwtManager   *JWTManager
+	loginLimiter *LoginLimiter
 }
 
-func NewAuthServer(userStore repository.UserStore, jwtManager *JWTManager) *AuthServer {
-	return &AuthServer{
-		userStore:  userStore,
-		jwtManager: jwtManager,
+type AuthServerOption func(*AuthServer)
+
+// WithLoginLimiter sets how failed logins lock out usernames and clients.
+func WithLoginLimiter(loginLimiter *LoginLimiter) AuthServerOption {
+	return func(server *AuthServer) {
+		server.loginLimiter = loginLimiter
 	}
 }
 
+func NewAuthServer(userStore repository.UserStore, jwtManager *JWTManager, opts ...AuthServerOption) *AuthServer {
+	server := &AuthServer{
+		userStore:    userStore,
+		jwtManager:   jwtManager,
+		loginLimiter: NewLoginLimiter(),
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server
+}
+
 func (server *AuthServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	ip := peerIP(ctx)
+	if wait := server.loginLimiter.Check(req.GetUsername(), ip); wait > 0 {
+		return nil, lockedOutError(wait)
+	}
+
 	user, err := server.userStore.Find(req.Username)
 	if err != nil {
 		return nil, err
 	}
 
 	if user == nil || !user.IsCorrectPassword(req.GetPassword()) {
+		if wait := server.loginLimiter.Fail(req.GetUsername(), ip); wait > 0 {
+			log.Printf("lock out user: %s, IP: %s for %v", req.GetUsername(), ip, wait)
+			return nil, lockedOutError(wait)
+		}
 		return nil, status.Errorf(codes.NotFound, "incorrect username/password")
 	}
 
-	token, err := server.jwtManager.Generate(user)
+	server.loginLimiter.Succeed(user.Username)
+
+	accessToken, refreshToken, err := server.jwtManager.GenerateRefresh(user, "")
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "cannot generate access token")
 	}
 
 	res := &pb.LoginResponse{
-		AccessToken: token,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+	return res, nil
+}
+
+// RefreshToken rotates a refresh token: the given token is revoked and a new
+// pair of tokens of the same session is returned.
+func (server *AuthServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	claims, err := server.jwtManager.VerifyRefresh(req.GetRefreshToken())
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token is invalid: %v", err)
+	}
+	log.Printf("receive a refresh-token request for user: %s", claims.Username)
+
+	// the role may have changed, and the user may be gone, since the login
+	user, err := server.userStore.Find(claims.Username)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find user: %v", err))
+	}
+
+	if user == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "user %s doesn't exist", claims.Username)
+	}
+
+	if claims.Epoch != user.TokenEpoch {
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token was issued before the password changed")
+	}
+
+	err = server.jwtManager.Rotate(claims)
+	if errors.Is(err, ErrTokenRevoked) {
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token is invalid: %v", err)
+	}
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "%v", err))
+	}
+
+	accessToken, refreshToken, err := server.jwtManager.GenerateRefresh(user, claims.SessionID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot generate access token")
+	}
+
+	return &pb.RefreshTokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Logout revokes the session of the refresh token, so neither its access
+// tokens nor its refresh tokens are accepted anymore.
+func (server *AuthServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	claims, err := server.jwtManager.VerifyRefresh(req.GetRefreshToken())
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token is invalid: %v", err)
+	}
+	log.Printf("receive a logout request for user: %s", claims.Username)
+
+	err = server.jwtManager.RevokeSession(claims)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "%v", err))
+	}
+
+	return &pb.LogoutResponse{}, nil
+}
+
+// Register creates a user with the user role. Admins are promoted with
+// UpdateUserRole.
+func (server *AuthServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	username := req.GetUsername()
+	log.Printf("receive a register request for user: %s", username)
+
+	err := entity.ValidateUsername(username)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	err = entity.ValidatePassword(username, req.GetPassword())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	user, err := entity.NewUser(username, req.GetPassword(), entity.ROLE_USER)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot create user: %v", err))
+	}
+
+	err = server.userStore.Save(user)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			code = codes.AlreadyExists
+		}
+		return nil, status.Errorf(code, "cannot save user to the store: %v", err)
+	}
+
+	return &pb.RegisterResponse{
+		User: toPbUser(user),
+	}, nil
+}
+
+// ChangePassword changes the password of the authenticated user, who has to
+// confirm the current one. The refresh tokens issued before are no longer
+// accepted, so every session of the user, including the caller's, ends once
+// its access token expires.
+func (server *AuthServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, utils.LogError(status.Errorf(codes.Unauthenticated, "changing a password requires an authenticated user"))
+	}
+	log.Printf("receive a change-password request for user: %s", claims.Username)
+
+	user, err := server.userStore.Find(claims.Username)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find user: %v", err))
+	}
+
+	if user == nil || !user.IsCorrectPassword(req.GetOldPassword()) {
+		return nil, status.Errorf(codes.PermissionDenied, "incorrect password")
+	}
+
+	err = entity.ValidatePassword(user.Username, req.GetNewPassword())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	err = user.SetPassword(req.GetNewPassword())
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "%v", err))
+	}
+
+	err = server.userStore.Update(user)
+	if err != nil {
+		return nil, userStoreError(err)
+	}
+
+	return &pb.ChangePasswordResponse{}, nil
+}
+
+func (server *AuthServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	log.Printf("receive a list-users request with page size: %d", req.GetPageSize())
+
+	afterUsername, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	size := pageSize(req.GetPageSize())
+
+	// fetch one extra user to know whether there is a next page
+	users, err := server.userStore.List(ctx, afterUsername, size+1)
+	if err != nil {
+		if err := utils.ContextError(ctx); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "cannot list users: %v", err)
 	}
+
+	res := &pb.ListUsersResponse{}
+	if len(users) > size {
+		users = users[:size]
+		res.NextPageToken = encodePageToken(users[size-1].Username)
+	}
+
+	for _, user := range users {
+		res.Users = append(res.Users, toPbUser(user))
+	}
+
 	return res, nil
 }
+
+func (server *AuthServer) UpdateUserRole(ctx context.Context, req *pb.UpdateUserRoleRequest) (*pb.UpdateUserRoleResponse, error) {
+	username := req.GetUsername()
+	log.Printf("receive an update-user-role request for user: %s, role: %s", username, req.GetRole())
+
+	if !entity.IsValidRole(req.GetRole()) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", req.GetRole())
+	}
+
+	err := checkNotSelf(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := server.userStore.Find(username)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find user: %v", err))
+	}
+
+	if user == nil {
+		return nil, status.Errorf(codes.NotFound, "user %s doesn't exist", username)
+	}
+
+	user.Role = req.GetRole()
+	err = server.userStore.Update(user)
+	if err != nil {
+		return nil, userStoreError(err)
+	}
+
+	return &pb.UpdateUserRoleResponse{
+		User: toPbUser(user),
+	}, nil
+}
+
+func (server *AuthServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	username := req.GetUsername()
+	log.Printf("receive a delete-user request for user: %s", username)
+
+	err := checkNotSelf(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.userStore.Delete(username)
+	if err != nil {
+		return nil, userStoreError(err)
+	}
+
+	return &pb.DeleteUserResponse{
+		Username: username,
+	}, nil
+}
+
+// UnlockUser ends the lockout of a user or of a client IP after too many
+// failed logins.
+func (server *AuthServer) UnlockUser(ctx context.Context, req *pb.UnlockUserRequest) (*pb.UnlockUserResponse, error) {
+	username := req.GetUsername()
+	ip := req.GetIp()
+	log.Printf("receive an unlock-user request for user: %s, ip: %s", username, ip)
+
+	if username == "" && ip == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "username or ip is required")
+	}
+
+	if ip != "" && net.ParseIP(ip) == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "ip %s is invalid", ip)
+	}
+
+	if username != "" {
+		user, err := server.userStore.Find(username)
+		if err != nil {
+			return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find user: %v", err))
+		}
+
+		if user == nil {
+			return nil, status.Errorf(codes.NotFound, "user %s doesn't exist", username)
+		}
+	}
+
+	server.loginLimiter.Unlock(username, ip)
+
+	return &pb.UnlockUserResponse{
+		Username: username,
+		Ip:       ip,
+	}, nil
+}
+
+// lockedOutError tells the client when to retry in a RetryInfo detail.
+func lockedOutError(wait time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, "too many failed logins, try again later").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	if err != nil {
+		return utils.LogError(status.Errorf(codes.Internal, "cannot add retry info: %v", err))
+	}
+
+	return st.Err()
+}
+
+// checkNotSelf keeps admins from demoting or deleting themselves, which could
+// leave the server without any admin.
+func checkNotSelf(ctx context.Context, username string) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if ok && claims.Username == username {
+		return status.Errorf(codes.FailedPrecondition, "admins cannot change their own account")
+	}
+
+	return nil
+}
+
+func userStoreError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return status.Errorf(codes.NotFound, "user doesn't exist")
+	}
+
+	return utils.LogError(status.Errorf(codes.Internal, "cannot update user store: %v", err))
+}
+
+func toPbUser(user *entity.User) *pb.User {
+	return &pb.User{
+		Username: user.Username,
+		Role:     user.Role,
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// applyFieldMask copies every field named in mask from src into dst.
+// Fields that are unset in src are cleared in dst.
+func applyFieldMask(dst, src proto.Message, mask *fieldmaskpb.FieldMask) {
+	for _, path := range mask.GetPaths() {
+		copyField(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(path, "."))
+	}
+}
+
+func copyField(dst, src protoreflect.Message, path []string) {
+	field := dst.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if field == nil {
+		return
+	}
+
+	if len(path) == 1 {
+		if src.Has(field) {
+			dst.Set(field, src.Get(field))
+		} else {
+			dst.Clear(field)
+		}
+		return
+	}
+
+	copyField(dst.Mutable(field).Message(), src.Get(field).Message(), path[1:])
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	DEFAULT_HEALTH_CHECK_INTERVAL = 10 * time.Second
+	DEFAULT_HEALTH_CHECK_TIMEOUT  = 5 * time.Second
+)
+
+// HealthChecker is the grpc.health.v1 server. Every service is serving while
+// the stores it depends on are available, and the server as a whole, under
+// the empty service name, while all of its services are. Once Shutdown is
+// called, everything is reported as not serving for good.
+type HealthChecker struct {
+	*health.Server
+	stores map[string][]repository.HealthChecker
+}
+
+// NewHealthChecker reports the services as not serving until Update checks
+// the stores of each, which maps its full name to the stores it depends on.
+func NewHealthChecker(stores map[string][]repository.HealthChecker) *HealthChecker {
+	checker := &HealthChecker{
+		Server: health.NewServer(),
+		stores: stores,
+	}
+
+	checker.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	for service := range stores {
+		checker.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	return checker
+}
+
+// Update checks the stores, and sets the serving status of every service and
+// of the server accordingly.
+func (checker *HealthChecker) Update(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, DEFAULT_HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+
+	serving := true
+	for service, stores := range checker.stores {
+		status := healthpb.HealthCheckResponse_SERVING
+		for _, store := range stores {
+			err := store.CheckHealth(ctx)
+			if err != nil {
+				log.Printf("service %s is not serving: %v", service, err)
+				status = healthpb.HealthCheckResponse_NOT_SERVING
+				serving = false
+				break
+			}
+		}
+
+		checker.SetServingStatus(service, status)
+	}
+
+	status := healthpb.HealthCheckResponse_SERVING
+	if !serving {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	checker.SetServingStatus("", status)
+}
+
+// Run updates the serving statuses every interval until ctx is done.
+func (checker *HealthChecker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checker.Update(ctx)
+		}
+	}
+}
+
+// Statuses returns the serving status of the server, under the empty service
+// name, and of every service.
+func (checker *HealthChecker) Statuses() map[string]healthpb.HealthCheckResponse_ServingStatus {
+	services := []string{""}
+	for service := range checker.stores {
+		services = append(services, service)
+	}
+
+	statuses := make(map[string]healthpb.HealthCheckResponse_ServingStatus, len(services))
+	for _, service := range services {
+		res, err := checker.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			statuses[service] = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+			continue
+		}
+		statuses[service] = res.GetStatus()
+	}
+
+	return statuses
+}
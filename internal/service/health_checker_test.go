@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeHealthStore struct {
+	down atomic.Bool
+}
+
+func (store *fakeHealthStore) CheckHealth(ctx context.Context) error {
+	if store.down.Load() {
+		return errors.New("store is down")
+	}
+	return nil
+}
+
+func TestHealthChecker(t *testing.T) {
+	t.Parallel()
+
+	laptopStore := &fakeHealthStore{}
+	userStore := &fakeHealthStore{}
+	checker := service.NewHealthChecker(map[string][]repository.HealthChecker{
+		"laptop": {laptopStore},
+		"auth":   {userStore, laptopStore},
+		"review": {},
+	})
+
+	requireStatuses := func(server, laptop, auth, review healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		require.Equal(t, map[string]healthpb.HealthCheckResponse_ServingStatus{
+			"":       server,
+			"laptop": laptop,
+			"auth":   auth,
+			"review": review,
+		}, checker.Statuses())
+	}
+
+	serving := healthpb.HealthCheckResponse_SERVING
+	notServing := healthpb.HealthCheckResponse_NOT_SERVING
+
+	requireStatuses(notServing, notServing, notServing, notServing)
+
+	checker.Update(context.Background())
+	requireStatuses(serving, serving, serving, serving)
+
+	userStore.down.Store(true)
+	checker.Update(context.Background())
+	requireStatuses(notServing, serving, notServing, serving)
+
+	laptopStore.down.Store(true)
+	checker.Update(context.Background())
+	requireStatuses(notServing, notServing, notServing, serving)
+
+	laptopStore.down.Store(false)
+	userStore.down.Store(false)
+	checker.Update(context.Background())
+	requireStatuses(serving, serving, serving, serving)
+
+	// once draining, the stores no longer matter
+	checker.Shutdown()
+	checker.Update(context.Background())
+	requireStatuses(notServing, notServing, notServing, notServing)
+}
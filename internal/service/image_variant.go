@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MAX_IMAGE_PIXELS = 50_000_000
+	VARIANT_QUALITY  = 85
+)
+
+// ImageVariant configures a resized rendition generated for every uploaded
+// image, scaled down so that its longest side is at most Size pixels.
+type ImageVariant struct {
+	Name string
+	Size int
+}
+
+// decodeImage decodes an uploaded image, refusing images whose dimensions
+// would take too much memory to decode.
+func decodeImage(imageData io.Reader) (image.Image, error) {
+	header := &bytes.Buffer{}
+	config, _, err := image.DecodeConfig(io.TeeReader(imageData, header))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > MAX_IMAGE_PIXELS {
+		return nil, fmt.Errorf("image is too large: %dx%d pixels", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(io.MultiReader(header, imageData))
+	return img, err
+}
+
+// resizeImage scales img down to fit in a size x size box, keeping its
+// aspect ratio. Images that already fit are returned unchanged.
+func resizeImage(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width <= size && height <= size {
+		return img
+	}
+
+	if width >= height {
+		height = height * size / width
+		width = size
+	} else {
+		width = width * size / height
+		height = size
+	}
+
+	if width == 0 {
+		width = 1
+	}
+
+	if height == 0 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	return dst
+}
+
+// encodeImage encodes img in the format of the original image and returns
+// the content type it used. WebP images are encoded as PNG, since there is
+// no pure-Go WebP encoder.
+func encodeImage(img image.Image, contentType string) (*bytes.Buffer, string, error) {
+	buffer := &bytes.Buffer{}
+
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(buffer, img, &jpeg.Options{Quality: VARIANT_QUALITY})
+	case "image/gif":
+		err = gif.Encode(buffer, img, nil)
+	default:
+		contentType = "image/png"
+		err = png.Encode(buffer, img)
+	}
+
+	return buffer, contentType, err
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResizeImage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		width  int
+		height int
+		size   int
+		want   image.Point
+	}{
+		{
+			name:   "landscape",
+			width:  800,
+			height: 600,
+			size:   128,
+			want:   image.Pt(128, 96),
+		},
+		{
+			name:   "portrait",
+			width:  600,
+			height: 800,
+			size:   128,
+			want:   image.Pt(96, 128),
+		},
+		{
+			name:   "already_fits",
+			width:  100,
+			height: 50,
+			size:   128,
+			want:   image.Pt(100, 50),
+		},
+		{
+			name:   "thin_strip",
+			width:  1000,
+			height: 2,
+			size:   100,
+			want:   image.Pt(100, 1),
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			img := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+			resized := resizeImage(img, tc.size)
+			require.Equal(t, tc.want, resized.Bounds().Size())
+		})
+	}
+}
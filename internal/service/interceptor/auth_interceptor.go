@@ -3,6 +3,8 @@ package interceptor
 import (
 	"context"
 	"log"
+	"strings"
+	"sync/atomic"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
 	"google.golang.org/grpc"
@@ -12,15 +14,23 @@ import (
 )
 
 type AuthInterceptor struct {
-	jwtManager      *service.JWTManager
-	accessibleRoles map[string][]string
+	jwtManager *service.JWTManager
+	policy     atomic.Pointer[Policy]
 }
 
-func NewAuthInterceptor(jwtManager *service.JWTManager, accessibleRoles map[string][]string) *AuthInterceptor {
-	return &AuthInterceptor{
-		jwtManager:      jwtManager,
-		accessibleRoles: accessibleRoles,
+func NewAuthInterceptor(jwtManager *service.JWTManager, policy *Policy) *AuthInterceptor {
+	interceptor := &AuthInterceptor{
+		jwtManager: jwtManager,
 	}
+	interceptor.policy.Store(policy)
+
+	return interceptor
+}
+
+// SetPolicy replaces the policy of the following requests, while the ones in
+// progress keep the policy they were authorized with.
+func (interceptor *AuthInterceptor) SetPolicy(policy *Policy) {
+	interceptor.policy.Store(policy)
 }
 
 func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
@@ -32,7 +42,7 @@ func (interceptor *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
 	) (resp interface{}, err error) {
 		log.Println("====== [Unary Interceptor] ", info.FullMethod)
 
-		err = interceptor.authorize(ctx, info.FullMethod)
+		ctx, err = interceptor.authorize(ctx, info.FullMethod)
 		if err != nil {
 			return nil, err
 		}
@@ -50,43 +60,102 @@ func (interceptor *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
 	) error {
 		log.Println("====== [Stream Interceptor] ", info.FullMethod)
 
-		err := interceptor.authorize(stream.Context(), info.FullMethod)
+		ctx, err := interceptor.authorize(stream.Context(), info.FullMethod)
 		if err != nil {
 			return err
 		}
 
-		return handler(srv, stream)
+		return handler(srv, &authorizedStream{ServerStream: stream, ctx: ctx})
 	}
 }
 
-func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) error {
-	accessibleRoles, ok := interceptor.accessibleRoles[method]
-	if !ok {
-		// everyone can access
-		return nil
+// HasPermission reports whether the role has the permission in the current
+// policy.
+func (interceptor *AuthInterceptor) HasPermission(role string, permission string) bool {
+	return interceptor.policy.Load().HasPermission(role, permission)
+}
+
+// authorize checks that the caller may access method and returns a context
+// carrying the caller's claims for the handler.
+func (interceptor *AuthInterceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	policy := interceptor.policy.Load()
+	if !policy.RequiresAuth(method) {
+		// everyone can access, and the handler still gets the claims of a
+		// valid token for what only some users may see
+		return interceptor.optionalClaims(ctx), nil
 	}
 
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return status.Errorf(codes.Unauthenticated, "metadata is not provided")
+		return nil, status.Errorf(codes.Unauthenticated, "metadata is not provided")
 	}
 
 	values := md["authorization"]
 	if len(values) == 0 {
-		return status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+		return nil, status.Errorf(codes.Unauthenticated, "authorization token is not provided")
+	}
+
+	accessToken, err := parseAuthorization(values[0])
+	if err != nil {
+		return nil, err
 	}
 
-	accessToken := values[0]
 	claims, err := interceptor.jwtManager.Verify(accessToken)
 	if err != nil {
-		return status.Errorf(codes.Unauthenticated, "access token is invalid: %v", err)
+		return nil, status.Errorf(codes.Unauthenticated, "access token is invalid: %v", err)
 	}
 
-	for _, role := range accessibleRoles {
-		if role == claims.Role {
-			return nil
-		}
+	if policy.Allows(claims.Role, method) {
+		return service.ContextWithClaims(ctx, claims), nil
+	}
+
+	return nil, status.Errorf(codes.PermissionDenied, "no permission to access this RPC")
+}
+
+// optionalClaims adds the claims of the access token to the context, if there
+// is a valid one.
+func (interceptor *AuthInterceptor) optionalClaims(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return ctx
 	}
 
-	return status.Errorf(codes.PermissionDenied, "no permission to access this RPC")
+	accessToken, err := parseAuthorization(md["authorization"][0])
+	if err != nil {
+		return ctx
+	}
+
+	claims, err := interceptor.jwtManager.Verify(accessToken)
+	if err != nil {
+		return ctx
+	}
+
+	return service.ContextWithClaims(ctx, claims)
+}
+
+// parseAuthorization returns the token of an authorization value. gRPC clients
+// send the bare token, while REST clients send an Authorization header with
+// the Bearer scheme, which the gateway passes on as is.
+func parseAuthorization(value string) (string, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok {
+		return scheme, nil
+	}
+
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", status.Errorf(codes.Unauthenticated, "unsupported authorization scheme %q", scheme)
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+// authorizedStream replaces the context of a stream with the one returned by
+// authorize.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authorizedStream) Context() context.Context {
+	return stream.ctx
 }
@@ -0,0 +1,245 @@
+package interceptor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyFile is the declarative form of a Policy, read from YAML or JSON.
+type PolicyFile struct {
+	Roles map[string]PolicyRole `json:"roles" yaml:"roles"`
+	Rules []PolicyRule          `json:"rules" yaml:"rules"`
+}
+
+type PolicyRole struct {
+	Inherits    []string `json:"inherits" yaml:"inherits"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// PolicyRule lets Roles, and the roles with any of Permissions, call Methods,
+// which are full method names or prefixes ending with *.
+type PolicyRule struct {
+	Methods     []string `json:"methods" yaml:"methods"`
+	Roles       []string `json:"roles" yaml:"roles"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+	Public      bool     `json:"public" yaml:"public"`
+}
+
+// Policy decides which roles may call which methods.
+type Policy struct {
+	// includes maps every role to itself and the roles it inherits.
+	includes    map[string]map[string]bool
+	permissions map[string]map[string]bool
+	exact       map[string]*PolicyRule
+	wildcards   []policyWildcard
+}
+
+type policyWildcard struct {
+	prefix string
+	rule   *PolicyRule
+}
+
+// LoadPolicy reads a policy file, in JSON if its extension is .json and in
+// YAML otherwise.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read policy file: %w", err)
+	}
+
+	file := PolicyFile{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse policy file %s: %w", path, err)
+	}
+
+	policy, err := NewPolicy(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+// NewPolicy checks that the rules only refer to known roles and permissions,
+// and that the roles don't inherit from each other in a cycle.
+func NewPolicy(file PolicyFile) (*Policy, error) {
+	policy := &Policy{
+		includes:    make(map[string]map[string]bool),
+		permissions: make(map[string]map[string]bool),
+		exact:       make(map[string]*PolicyRule),
+	}
+
+	for name := range file.Roles {
+		err := policy.resolveRole(file.Roles, name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	granted := make(map[string]bool)
+	for _, permissions := range policy.permissions {
+		for permission := range permissions {
+			granted[permission] = true
+		}
+	}
+
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+
+		if rule.Public && (len(rule.Roles) > 0 || len(rule.Permissions) > 0) {
+			return nil, fmt.Errorf("public rule of %v cannot have roles or permissions", rule.Methods)
+		}
+		if !rule.Public && len(rule.Roles) == 0 && len(rule.Permissions) == 0 {
+			return nil, fmt.Errorf("rule of %v needs roles, permissions or to be public", rule.Methods)
+		}
+
+		for _, role := range rule.Roles {
+			if _, ok := policy.includes[role]; !ok {
+				return nil, fmt.Errorf("rule of %v refers to unknown role %q", rule.Methods, role)
+			}
+		}
+		for _, permission := range rule.Permissions {
+			if !granted[permission] {
+				return nil, fmt.Errorf("no role has permission %q", permission)
+			}
+		}
+
+		for _, method := range rule.Methods {
+			err := policy.addMethod(method, rule)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(policy.wildcards, func(i, j int) bool {
+		return len(policy.wildcards[i].prefix) > len(policy.wildcards[j].prefix)
+	})
+
+	return policy, nil
+}
+
+func (policy *Policy) resolveRole(roles map[string]PolicyRole, name string, path []string) error {
+	if _, ok := policy.includes[name]; ok {
+		return nil
+	}
+
+	for _, other := range path {
+		if other == name {
+			return fmt.Errorf("roles inherit from each other: %s", strings.Join(append(path, name), " -> "))
+		}
+	}
+
+	role, ok := roles[name]
+	if !ok {
+		return fmt.Errorf("role %q inherits unknown role %q", path[len(path)-1], name)
+	}
+
+	includes := map[string]bool{name: true}
+	permissions := make(map[string]bool)
+	for _, permission := range role.Permissions {
+		permissions[permission] = true
+	}
+
+	for _, parent := range role.Inherits {
+		err := policy.resolveRole(roles, parent, append(path, name))
+		if err != nil {
+			return err
+		}
+
+		for included := range policy.includes[parent] {
+			includes[included] = true
+		}
+		for permission := range policy.permissions[parent] {
+			permissions[permission] = true
+		}
+	}
+
+	policy.includes[name] = includes
+	policy.permissions[name] = permissions
+
+	return nil
+}
+
+func (policy *Policy) addMethod(method string, rule *PolicyRule) error {
+	prefix, wildcard := strings.CutSuffix(method, "*")
+	if !strings.HasPrefix(method, "/") || strings.Contains(prefix, "*") {
+		return fmt.Errorf("invalid method %q, want /package.Service/Method or a prefix ending with *", method)
+	}
+
+	if !wildcard {
+		if _, ok := policy.exact[method]; ok {
+			return fmt.Errorf("method %q has more than one rule", method)
+		}
+		policy.exact[method] = rule
+		return nil
+	}
+
+	for _, other := range policy.wildcards {
+		if other.prefix == prefix {
+			return fmt.Errorf("method %q has more than one rule", method)
+		}
+	}
+	policy.wildcards = append(policy.wildcards, policyWildcard{prefix: prefix, rule: rule})
+
+	return nil
+}
+
+func (policy *Policy) rule(method string) *PolicyRule {
+	if rule, ok := policy.exact[method]; ok {
+		return rule
+	}
+
+	for _, wildcard := range policy.wildcards {
+		if strings.HasPrefix(method, wildcard.prefix) {
+			return wildcard.rule
+		}
+	}
+
+	return nil
+}
+
+// RequiresAuth reports whether only some roles may call the method.
+func (policy *Policy) RequiresAuth(method string) bool {
+	rule := policy.rule(method)
+	return rule != nil && !rule.Public
+}
+
+// Allows reports whether a user with the role may call the method.
+func (policy *Policy) Allows(role string, method string) bool {
+	rule := policy.rule(method)
+	if rule == nil || rule.Public {
+		return true
+	}
+
+	for _, allowed := range rule.Roles {
+		if policy.includes[role][allowed] {
+			return true
+		}
+	}
+
+	for _, permission := range rule.Permissions {
+		if policy.permissions[role][permission] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether the role has the permission, itself or through
+// the roles it inherits.
+func (policy *Policy) HasPermission(role string, permission string) bool {
+	return policy.permissions[role][permission]
+}
@@ -0,0 +1,185 @@
+package interceptor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service/interceptor"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+roles:
+  guest:
+    permissions: [laptop.read]
+  user:
+    inherits: [guest]
+    permissions: [laptop.rate]
+  admin:
+    inherits: [user]
+    permissions: [laptop.write]
+rules:
+  - methods: [/playingwithgolang.grpc.LaptopService/*]
+    permissions: [laptop.read]
+  - methods: [/playingwithgolang.grpc.LaptopService/Create*]
+    permissions: [laptop.write]
+  - methods: [/playingwithgolang.grpc.LaptopService/RateLaptop]
+    roles: [user]
+  - methods: [/playingwithgolang.grpc.LaptopService/SearchLaptop]
+    public: true
+`
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := interceptor.LoadPolicy(writeTestPolicy(t, "policy.yaml", testPolicy))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		method       string
+		requiresAuth bool
+		allowed      []string
+	}{
+		{pb.LaptopService_GetLaptop_FullMethodName, true, []string{"guest", "user", "admin"}},
+		{pb.LaptopService_CreateLaptop_FullMethodName, true, []string{"admin"}},
+		{pb.LaptopService_RateLaptop_FullMethodName, true, []string{"user", "admin"}},
+		{pb.LaptopService_SearchLaptop_FullMethodName, false, []string{"guest", "user", "admin", "unknown"}},
+		{pb.AuthService_Login_FullMethodName, false, []string{"guest", "user", "admin", "unknown"}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.method, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.requiresAuth, policy.RequiresAuth(tc.method))
+			for _, role := range []string{"guest", "user", "admin", "unknown"} {
+				require.Equal(t, contains(tc.allowed, role), policy.Allows(role, tc.method), role)
+			}
+		})
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	t.Parallel()
+
+	policy, err := interceptor.LoadPolicy(writeTestPolicy(t, "policy.json", `{
+		"roles": {"user": {"permissions": ["review.write"]}},
+		"rules": [{"methods": ["/playingwithgolang.grpc.ReviewService/CreateReview"], "permissions": ["review.write"]}]
+	}`))
+	require.NoError(t, err)
+	require.True(t, policy.Allows("user", pb.ReviewService_CreateReview_FullMethodName))
+	require.False(t, policy.Allows("admin", pb.ReviewService_CreateReview_FullMethodName))
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		policy interceptor.PolicyFile
+	}{
+		{
+			name: "inheritance cycle",
+			policy: interceptor.PolicyFile{Roles: map[string]interceptor.PolicyRole{
+				"user":  {Inherits: []string{"admin"}},
+				"admin": {Inherits: []string{"user"}},
+			}},
+		},
+		{
+			name: "unknown inherited role",
+			policy: interceptor.PolicyFile{Roles: map[string]interceptor.PolicyRole{
+				"admin": {Inherits: []string{"user"}},
+			}},
+		},
+		{
+			name: "unknown role",
+			policy: interceptor.PolicyFile{Rules: []interceptor.PolicyRule{
+				{Methods: []string{"/a.Service/Method"}, Roles: []string{"admin"}},
+			}},
+		},
+		{
+			name: "unknown permission",
+			policy: interceptor.PolicyFile{Rules: []interceptor.PolicyRule{
+				{Methods: []string{"/a.Service/Method"}, Permissions: []string{"laptop.write"}},
+			}},
+		},
+		{
+			name: "no roles",
+			policy: interceptor.PolicyFile{Rules: []interceptor.PolicyRule{
+				{Methods: []string{"/a.Service/Method"}},
+			}},
+		},
+		{
+			name: "public with roles",
+			policy: interceptor.PolicyFile{
+				Roles: map[string]interceptor.PolicyRole{"admin": {}},
+				Rules: []interceptor.PolicyRule{
+					{Methods: []string{"/a.Service/Method"}, Roles: []string{"admin"}, Public: true},
+				},
+			},
+		},
+		{
+			name: "wildcard in the middle",
+			policy: interceptor.PolicyFile{Rules: []interceptor.PolicyRule{
+				{Methods: []string{"/a.*/Method"}, Public: true},
+			}},
+		},
+		{
+			name: "duplicate method",
+			policy: interceptor.PolicyFile{Rules: []interceptor.PolicyRule{
+				{Methods: []string{"/a.Service/*"}, Public: true},
+				{Methods: []string{"/a.Service/*"}, Public: true},
+			}},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := interceptor.NewPolicy(tc.policy)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := interceptor.LoadPolicy("../../../config/rbac.yaml")
+	require.NoError(t, err)
+
+	require.False(t, policy.RequiresAuth(pb.AuthService_Login_FullMethodName))
+	require.False(t, policy.RequiresAuth(pb.LaptopService_SearchLaptop_FullMethodName))
+	require.True(t, policy.Allows("user", pb.AuthService_ChangePassword_FullMethodName))
+	require.False(t, policy.Allows("user", pb.AuthService_UnlockUser_FullMethodName))
+	require.True(t, policy.Allows("admin", pb.AuthService_UnlockUser_FullMethodName))
+	require.True(t, policy.Allows("admin", pb.LaptopService_RateLaptop_FullMethodName))
+	require.False(t, policy.Allows("user", pb.ReviewService_ModerateReview_FullMethodName))
+	require.True(t, policy.HasPermission("admin", "review.moderate"))
+	require.True(t, policy.HasPermission("admin", "review.write"))
+	require.False(t, policy.HasPermission("user", "review.moderate"))
+	require.False(t, policy.HasPermission("unknown", "review.write"))
+}
+
+func writeTestPolicy(t *testing.T, name string, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(data), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
+
+func contains(values []string, value string) bool {
+	for _, other := range values {
+		if other == value {
+			return true
+		}
+	}
+
+	return false
+}
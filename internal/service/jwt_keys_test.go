@@ -0,0 +1,149 @@
+package service_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTManagerSigningKeys(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		key     crypto.Signer
+		alg     string
+		keyType string
+	}{
+		{"RSA", rsaKey, "RS256", "RSA"},
+		{"ECDSA", ecdsaKey, "ES256", "EC"},
+		{"Ed25519", ed25519Key, "EdDSA", "OKP"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyFile := writeTestPrivateKey(t, tc.key)
+			key, err := service.LoadSigningKey(keyFile)
+			require.NoError(t, err)
+			require.Equal(t, tc.alg, key.Method.Alg())
+
+			publicKeyFile := writeTestPublicKey(t, tc.key.Public())
+			publicKey, err := service.LoadVerificationKey(publicKeyFile)
+			require.NoError(t, err)
+			require.Equal(t, key.ID, publicKey.ID)
+			require.Nil(t, publicKey.PrivateKey)
+
+			signer := service.NewJWTManager("", time.Minute, service.WithSigningKey(key))
+			verifier := service.NewJWTManager("", time.Minute, service.WithVerificationKeys(publicKey))
+
+			accessToken, err := signer.Generate(&entity.User{Username: "alice", Role: "user"})
+			require.NoError(t, err)
+
+			token, _, err := jwt.NewParser().ParseUnverified(accessToken, &service.UserClaims{})
+			require.NoError(t, err)
+			require.Equal(t, key.ID, token.Header["kid"])
+			require.Equal(t, tc.alg, token.Header["alg"])
+
+			claims, err := verifier.Verify(accessToken)
+			require.NoError(t, err)
+			require.Equal(t, "alice", claims.Username)
+
+			jwks := verifier.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			require.Equal(t, key.ID, jwks.Keys[0].KeyID)
+			require.Equal(t, tc.alg, jwks.Keys[0].Algorithm)
+			require.Equal(t, tc.keyType, jwks.Keys[0].KeyType)
+		})
+	}
+}
+
+func TestJWTManagerKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey := newTestSigningKey(t)
+	newKey := newTestSigningKey(t)
+	user := &entity.User{Username: "alice", Role: "user"}
+
+	oldManager := service.NewJWTManager("", time.Minute, service.WithSigningKey(oldKey))
+	oldToken, err := oldManager.Generate(user)
+	require.NoError(t, err)
+
+	newManager := service.NewJWTManager("", time.Minute,
+		service.WithSigningKey(newKey),
+		service.WithVerificationKeys(oldKey),
+	)
+	newToken, err := newManager.Generate(user)
+	require.NoError(t, err)
+
+	_, err = newManager.Verify(oldToken)
+	require.NoError(t, err)
+	_, err = newManager.Verify(newToken)
+	require.NoError(t, err)
+
+	_, err = oldManager.Verify(newToken)
+	require.Error(t, err)
+
+	require.Len(t, newManager.JWKS().Keys, 2)
+
+	// without a secret key, HS256 tokens are rejected
+	hmacToken, err := service.NewJWTManager("secret", time.Minute).Generate(user)
+	require.NoError(t, err)
+	_, err = newManager.Verify(hmacToken)
+	require.Error(t, err)
+}
+
+func newTestSigningKey(t *testing.T) *service.SigningKey {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key, err := service.LoadSigningKey(writeTestPrivateKey(t, privateKey))
+	require.NoError(t, err)
+
+	return key
+}
+
+func writeTestPrivateKey(t *testing.T, key crypto.Signer) string {
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return writeTestPEM(t, "PRIVATE KEY", data)
+}
+
+func writeTestPublicKey(t *testing.T, key crypto.PublicKey) string {
+	data, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	return writeTestPEM(t, "PUBLIC KEY", data)
+}
+
+func writeTestPEM(t *testing.T, blockType string, data []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600)
+	require.NoError(t, err)
+
+	return path
+}
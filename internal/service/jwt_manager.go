@@ -1,62 +1,404 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
-	"github.com/dgrijalva/jwt-go"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const (
+	DEFAULT_REFRESH_TOKEN_DURATION = 7 * 24 * time.Hour
+	DEFAULT_TOKEN_ISSUER           = "auth-service"
+	DEFAULT_TOKEN_AUDIENCE         = "laptop-service"
+	DEFAULT_TOKEN_LEEWAY           = 30 * time.Second
+
+	ACCESS_TOKEN  = "access"
+	REFRESH_TOKEN = "refresh"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// JWTManager signs tokens with its signing key, or with HS256 and the secret
+// key when it has none, and verifies tokens signed with any of its
+// verification keys, which allows rotating the signing key.
 type JWTManager struct {
-	secretKey     string
-	tokenDuration time.Duration
+	secretKey            string
+	signingKey           *SigningKey
+	verificationKeys     map[string]*SigningKey
+	tokenDuration        time.Duration
+	refreshTokenDuration time.Duration
+	revocationStore      repository.RevocationStore
+	issuer               string
+	audience             string
+	leeway               time.Duration
+	parser               *jwt.Parser
 }
 
+// UserClaims identify the token with the standard jti claim. Every token
+// issued from the same login shares a session ID, so the whole session can be
+// revoked at once, and carries the token epoch of the user, so all of the
+// sessions end when the password changes.
 type UserClaims struct {
-	jwt.StandardClaims
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	jwt.RegisteredClaims
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Type      string `json:"typ"`
+	SessionID string `json:"sid"`
+	Epoch     int    `json:"epoch,omitempty"`
+}
+
+type claimsKey struct{}
+
+// ContextWithClaims returns a copy of ctx carrying the claims of the user
+// that sent the request.
+func ContextWithClaims(ctx context.Context, claims *UserClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by ContextWithClaims, if any.
+func ClaimsFromContext(ctx context.Context) (*UserClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*UserClaims)
+	return claims, ok
 }
 
-func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
-	return &JWTManager{
-		secretKey:     secretKey,
-		tokenDuration: tokenDuration,
+type JWTManagerOption func(*JWTManager)
+
+// WithRefreshTokenDuration sets how long a refresh token can be used.
+func WithRefreshTokenDuration(duration time.Duration) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.refreshTokenDuration = duration
 	}
 }
 
+// WithRevocationStore sets where revoked tokens and sessions are remembered.
+func WithRevocationStore(revocationStore repository.RevocationStore) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.revocationStore = revocationStore
+	}
+}
+
+// WithSigningKey sets the asymmetric key that signs new tokens, which also
+// verifies them.
+func WithSigningKey(key *SigningKey) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.signingKey = key
+		manager.verificationKeys[key.ID] = key
+	}
+}
+
+// WithVerificationKeys adds keys that verify tokens without signing new ones,
+// such as the previous signing key while its tokens are still valid.
+func WithVerificationKeys(keys ...*SigningKey) JWTManagerOption {
+	return func(manager *JWTManager) {
+		for _, key := range keys {
+			manager.verificationKeys[key.ID] = key
+		}
+	}
+}
+
+// WithIssuer sets the iss claim of new tokens, which verified tokens must
+// carry as well.
+func WithIssuer(issuer string) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.issuer = issuer
+	}
+}
+
+// WithAudience sets the aud claim of new tokens, which verified tokens must
+// contain as well.
+func WithAudience(audience string) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.audience = audience
+	}
+}
+
+// WithLeeway sets how much the clocks of the issuer and of the verifier may
+// differ when checking the exp, nbf and iat claims.
+func WithLeeway(leeway time.Duration) JWTManagerOption {
+	return func(manager *JWTManager) {
+		manager.leeway = leeway
+	}
+}
+
+// NewJWTManager returns a manager that falls back to HS256 tokens signed with
+// secretKey. An empty secretKey disables HS256.
+func NewJWTManager(secretKey string, tokenDuration time.Duration, opts ...JWTManagerOption) *JWTManager {
+	manager := &JWTManager{
+		secretKey:            secretKey,
+		verificationKeys:     make(map[string]*SigningKey),
+		tokenDuration:        tokenDuration,
+		refreshTokenDuration: DEFAULT_REFRESH_TOKEN_DURATION,
+		revocationStore:      repository.NewInMemoryRevocationStore(),
+		issuer:               DEFAULT_TOKEN_ISSUER,
+		audience:             DEFAULT_TOKEN_AUDIENCE,
+		leeway:               DEFAULT_TOKEN_LEEWAY,
+	}
+
+	for _, opt := range opts {
+		opt(manager)
+	}
+
+	manager.parser = jwt.NewParser(
+		jwt.WithValidMethods(manager.validMethods()),
+		jwt.WithIssuer(manager.issuer),
+		jwt.WithAudience(manager.audience),
+		jwt.WithLeeway(manager.leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+
+	return manager
+}
+
+// Generate returns an access token of a new session.
 func (manager *JWTManager) Generate(user *entity.User) (string, error) {
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("cannot generate session ID: %w", err)
+	}
+
+	return manager.sign(user, ACCESS_TOKEN, sessionID.String(), manager.tokenDuration)
+}
+
+// GenerateRefresh returns an access token and a refresh token of the session,
+// starting a new session when sessionID is empty.
+func (manager *JWTManager) GenerateRefresh(user *entity.User, sessionID string) (string, string, error) {
+	if sessionID == "" {
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", "", fmt.Errorf("cannot generate session ID: %w", err)
+		}
+		sessionID = id.String()
+	}
+
+	accessToken, err := manager.sign(user, ACCESS_TOKEN, sessionID, manager.tokenDuration)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := manager.sign(user, REFRESH_TOKEN, sessionID, manager.refreshTokenDuration)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (manager *JWTManager) sign(user *entity.User, tokenType string, sessionID string, duration time.Duration) (string, error) {
+	tokenID, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("cannot generate token ID: %w", err)
+	}
+
+	now := time.Now()
 	claims := UserClaims{
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(manager.tokenDuration).Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID.String(),
+			Issuer:    manager.issuer,
+			Subject:   user.Username,
+			Audience:  jwt.ClaimStrings{manager.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 		},
-		Username: user.Username,
-		Role:     user.Role,
+		Username:  user.Username,
+		Role:      user.Role,
+		Type:      tokenType,
+		SessionID: sessionID,
+		Epoch:     user.TokenEpoch,
+	}
+
+	if manager.signingKey != nil {
+		token := jwt.NewWithClaims(manager.signingKey.Method, claims)
+		token.Header["kid"] = manager.signingKey.ID
+		return token.SignedString(manager.signingKey.PrivateKey)
+	}
+
+	if manager.secretKey == "" {
+		return "", errors.New("no signing key")
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(manager.secretKey))
 }
 
-func (manager *JWTManager) Verify(accessToken string) (*UserClaims, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
+// JWKS returns the public verification keys, so other services can verify
+// the tokens without sharing a secret.
+func (manager *JWTManager) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range manager.verificationKeys {
+		jwks.Keys = append(jwks.Keys, key.JWK())
+	}
 
-		return []byte(manager.secretKey), nil
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID
 	})
 
+	return jwks
+}
+
+// Verify checks an access token.
+func (manager *JWTManager) Verify(accessToken string) (*UserClaims, error) {
+	claims, err := manager.parse(accessToken, ACCESS_TOKEN)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse access token: %w", err)
 	}
 
+	err = manager.checkRevoked(claims.ID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// VerifyRefresh checks a refresh token. A refresh token that was already
+// rotated may have been stolen, so presenting it again revokes its session.
+func (manager *JWTManager) VerifyRefresh(refreshToken string) (*UserClaims, error) {
+	claims, err := manager.parse(refreshToken, REFRESH_TOKEN)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse refresh token: %w", err)
+	}
+
+	err = manager.checkRevoked(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.checkRevoked(claims.ID)
+	if errors.Is(err, ErrTokenRevoked) {
+		revokeErr := manager.RevokeSession(claims)
+		if revokeErr != nil {
+			return nil, revokeErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Rotate revokes a refresh token that is exchanged for a new one. Rotating a
+// token that was already rotated, even by a concurrent request, revokes its
+// session like VerifyRefresh does.
+func (manager *JWTManager) Rotate(claims *UserClaims) error {
+	revoked, err := manager.revocationStore.RevokeOnce(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("cannot revoke token: %w", err)
+	}
+
+	if !revoked {
+		err := manager.RevokeSession(claims)
+		if err != nil {
+			return err
+		}
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// Revoke revokes a single token until it expires.
+func (manager *JWTManager) Revoke(claims *UserClaims) error {
+	err := manager.revocationStore.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return fmt.Errorf("cannot revoke token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeSession revokes every token of the session, including the ones that
+// the refresh tokens of the session may still issue.
+func (manager *JWTManager) RevokeSession(claims *UserClaims) error {
+	expiresAt := time.Now().Add(manager.refreshTokenDuration)
+	err := manager.revocationStore.Revoke(claims.SessionID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("cannot revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (manager *JWTManager) parse(tokenString string, tokenType string) (*UserClaims, error) {
+	token, err := manager.parser.ParseWithClaims(tokenString, &UserClaims{}, manager.verificationKey)
+
+	if err != nil {
+		return nil, err
+	}
+
 	claims, ok := token.Claims.(*UserClaims)
 	if !ok {
 		return nil, fmt.Errorf("cannot convert claims to UserClaims")
 	}
 
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("unexpected token type: %q", claims.Type)
+	}
+
 	return claims, nil
 }
+
+// validMethods lists the algorithms of the verification keys, and HS256 when
+// there is a secret key, so tokens with any other alg header are rejected
+// before looking for their key.
+func (manager *JWTManager) validMethods() []string {
+	methods := []string{}
+	if manager.secretKey != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	for _, key := range manager.verificationKeys {
+		methods = append(methods, key.Method.Alg())
+	}
+
+	return methods
+}
+
+// verificationKey picks the key of a token from its kid header. The algorithm
+// must be the one of the key, so a public key is never used as an HMAC secret.
+func (manager *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		if !ok || manager.secretKey == "" {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(manager.secretKey), nil
+	}
+
+	key, ok := manager.verificationKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+func (manager *JWTManager) checkRevoked(ids ...string) error {
+	for _, id := range ids {
+		revoked, err := manager.revocationStore.IsRevoked(id)
+		if err != nil {
+			return fmt.Errorf("cannot check token revocation: %w", err)
+		}
+
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
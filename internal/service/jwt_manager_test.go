@@ -0,0 +1,220 @@
+package service_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/entity"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTManagerRefresh(t *testing.T) {
+	t.Parallel()
+
+	jwtManager := service.NewJWTManager("secret", time.Minute)
+	user := &entity.User{Username: "alice", Role: "user"}
+
+	accessToken, refreshToken, err := jwtManager.GenerateRefresh(user, "")
+	require.NoError(t, err)
+
+	claims, err := jwtManager.Verify(accessToken)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims.Username)
+	require.NotEmpty(t, claims.ID)
+	require.NotEmpty(t, claims.SessionID)
+
+	_, err = jwtManager.Verify(refreshToken)
+	require.Error(t, err)
+
+	_, err = jwtManager.VerifyRefresh(accessToken)
+	require.Error(t, err)
+
+	refreshClaims, err := jwtManager.VerifyRefresh(refreshToken)
+	require.NoError(t, err)
+	require.Equal(t, claims.SessionID, refreshClaims.SessionID)
+
+	err = jwtManager.Revoke(refreshClaims)
+	require.NoError(t, err)
+
+	rotatedAccessToken, rotatedRefreshToken, err := jwtManager.GenerateRefresh(user, refreshClaims.SessionID)
+	require.NoError(t, err)
+
+	_, err = jwtManager.VerifyRefresh(rotatedRefreshToken)
+	require.NoError(t, err)
+
+	// reusing the rotated refresh token revokes the whole session
+	_, err = jwtManager.VerifyRefresh(refreshToken)
+	require.ErrorIs(t, err, service.ErrTokenRevoked)
+
+	_, err = jwtManager.VerifyRefresh(rotatedRefreshToken)
+	require.ErrorIs(t, err, service.ErrTokenRevoked)
+
+	_, err = jwtManager.Verify(rotatedAccessToken)
+	require.ErrorIs(t, err, service.ErrTokenRevoked)
+
+	_, err = jwtManager.Verify(accessToken)
+	require.ErrorIs(t, err, service.ErrTokenRevoked)
+
+	otherAccessToken, err := jwtManager.Generate(user)
+	require.NoError(t, err)
+
+	_, err = jwtManager.Verify(otherAccessToken)
+	require.NoError(t, err)
+}
+
+func TestJWTManagerExpiredRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	jwtManager := service.NewJWTManager("secret", time.Minute, service.WithRefreshTokenDuration(-time.Minute))
+	user := &entity.User{Username: "alice", Role: "user"}
+
+	_, refreshToken, err := jwtManager.GenerateRefresh(user, "")
+	require.NoError(t, err)
+
+	_, err = jwtManager.VerifyRefresh(refreshToken)
+	require.Error(t, err)
+}
+
+func TestJWTManagerClaimValidation(t *testing.T) {
+	t.Parallel()
+
+	key := newTestSigningKey(t)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwtManager := service.NewJWTManager("", time.Minute,
+		service.WithSigningKey(key),
+		service.WithLeeway(time.Minute),
+	)
+
+	testCases := []struct {
+		name   string
+		method jwt.SigningMethod
+		key    interface{}
+		claims func(claims *service.UserClaims)
+		err    error
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "expired",
+			claims: func(claims *service.UserClaims) {
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Minute))
+			},
+			err: jwt.ErrTokenExpired,
+		},
+		{
+			name: "expired within leeway",
+			claims: func(claims *service.UserClaims) {
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+			},
+		},
+		{
+			name:   "no expiration",
+			claims: func(claims *service.UserClaims) { claims.ExpiresAt = nil },
+			err:    jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "not yet valid",
+			claims: func(claims *service.UserClaims) {
+				claims.NotBefore = jwt.NewNumericDate(time.Now().Add(2 * time.Minute))
+			},
+			err: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name: "not yet valid within leeway",
+			claims: func(claims *service.UserClaims) {
+				claims.NotBefore = jwt.NewNumericDate(time.Now().Add(30 * time.Second))
+			},
+		},
+		{
+			name: "issued in the future",
+			claims: func(claims *service.UserClaims) {
+				claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(2 * time.Minute))
+			},
+			err: jwt.ErrTokenUsedBeforeIssued,
+		},
+		{
+			name:   "wrong audience",
+			claims: func(claims *service.UserClaims) { claims.Audience = jwt.ClaimStrings{"other-service"} },
+			err:    jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:   "no audience",
+			claims: func(claims *service.UserClaims) { claims.Audience = nil },
+			err:    jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:   "wrong issuer",
+			claims: func(claims *service.UserClaims) { claims.Issuer = "other-service" },
+			err:    jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "public key as HMAC secret",
+			// a token claiming HS256 must not be verified with the public key bytes
+			method: jwt.SigningMethodHS256,
+			key:    []byte(key.PublicKey.(ed25519.PublicKey)),
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:   "no signature",
+			method: jwt.SigningMethodNone,
+			key:    jwt.UnsafeAllowNoneSignatureType,
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:   "other key",
+			method: jwt.SigningMethodEdDSA,
+			key:    otherKey,
+			err:    jwt.ErrTokenSignatureInvalid,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Now()
+			claims := &service.UserClaims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					ID:        "token",
+					Issuer:    service.DEFAULT_TOKEN_ISSUER,
+					Audience:  jwt.ClaimStrings{service.DEFAULT_TOKEN_AUDIENCE},
+					IssuedAt:  jwt.NewNumericDate(now),
+					NotBefore: jwt.NewNumericDate(now),
+					ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+				},
+				Username:  "mallory",
+				Role:      "admin",
+				Type:      service.ACCESS_TOKEN,
+				SessionID: "session",
+			}
+			if tc.claims != nil {
+				tc.claims(claims)
+			}
+
+			method, signingKey := tc.method, tc.key
+			if method == nil {
+				method, signingKey = key.Method, key.PrivateKey
+			}
+
+			token := jwt.NewWithClaims(method, claims)
+			token.Header["kid"] = key.ID
+			tokenString, err := token.SignedString(signingKey)
+			require.NoError(t, err)
+
+			_, err = jwtManager.Verify(tokenString)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -1,30 +1,124 @@
 package service
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"image"
 	"io"
 	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
 	"github.com/caiofernandes00/playing-with-golang/grpc/internal/utils"
 	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
 	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/api/httpbody"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-const MAX_IMAGE_SIZE = 1 << 20 // 1 MB
+const (
+	DEFAULT_MAX_IMAGE_SIZE = 1 << 20  // 1 MB
+	DOWNLOAD_CHUNK_SIZE    = 32 << 10 // 32 KB
+	SNIFF_SIZE             = 512
+	DEFAULT_MIN_SCORE      = 1
+	DEFAULT_MAX_SCORE      = 10
+	// GetLaptopRating lists every histogram bucket of the score range
+	MAX_SCORE_BUCKETS = 100
+)
 
 type LaptopServer struct {
-	laptopStore repository.LaptopStore
-	imageStore  repository.ImageStore
-	ratingStore repository.RatingStore
+	laptopStore  repository.LaptopStore
+	imageStore   repository.ImageStore
+	ratingStore  repository.RatingStore
+	reviewStore  repository.ReviewStore
+	uploadStore  repository.UploadStore
+	maxImageSize int64
+	variants     []ImageVariant
+	minScore     float64
+	maxScore     float64
+}
+
+type LaptopServerOption func(*LaptopServer)
+
+// WithMaxImageSize sets the largest image, in bytes, that UploadImage accepts.
+func WithMaxImageSize(size int64) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.maxImageSize = size
+	}
+}
+
+// WithUploadStore sets where image chunks are staged until the upload
+// completes. By default they are staged in the folder of the image store when
+// it keeps images in files, so that a finished upload is moved into place.
+func WithUploadStore(uploadStore repository.UploadStore) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.uploadStore = uploadStore
+	}
+}
+
+// WithReviewStore sets the store of the reviews deleted along with their
+// laptop.
+func WithReviewStore(reviewStore repository.ReviewStore) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.reviewStore = reviewStore
+	}
+}
+
+// WithImageVariants sets the resized renditions generated for every uploaded image.
+func WithImageVariants(variants ...ImageVariant) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.variants = variants
+	}
+}
+
+// WithScoreRange sets the lowest and highest score RateLaptop accepts.
+func WithScoreRange(min float64, max float64) LaptopServerOption {
+	return func(server *LaptopServer) {
+		server.minScore = min
+		server.maxScore = max
+	}
+}
+
+func NewLaptopServer(laptopStore repository.LaptopStore, imageStore repository.ImageStore, ratingStore repository.RatingStore, opts ...LaptopServerOption) *LaptopServer {
+	server := &LaptopServer{
+		laptopStore:  laptopStore,
+		imageStore:   imageStore,
+		ratingStore:  ratingStore,
+		maxImageSize: DEFAULT_MAX_IMAGE_SIZE,
+		minScore:     DEFAULT_MIN_SCORE,
+		maxScore:     DEFAULT_MAX_SCORE,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if server.uploadStore == nil {
+		server.uploadStore = repository.NewDiskUploadStore(UploadFolder(imageStore))
+	}
+
+	return server
 }
 
-func NewLaptopServer(laptopStore repository.LaptopStore, imageStore repository.ImageStore, ratingStore repository.RatingStore) *LaptopServer {
-	return &LaptopServer{laptopStore, imageStore, ratingStore}
+// UploadFolder returns the folder of the image store when it keeps images in
+// files, and the temporary directory otherwise.
+func UploadFolder(imageStore repository.ImageStore) string {
+	if fileStore, ok := imageStore.(repository.FileImageStore); ok {
+		return fileStore.StagingFolder()
+	}
+
+	return os.TempDir()
 }
 
 func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLaptopRequest) (*pb.CreateLaptopResponse, error) {
@@ -64,19 +158,213 @@ func (server *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLapt
 	}, nil
 }
 
+func (server *LaptopServer) GetLaptop(ctx context.Context, req *pb.GetLaptopRequest) (*pb.GetLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("receive a get-laptop request with id: %s", laptopID)
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot find laptop: %v", err)
+	}
+
+	if laptop == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID)
+	}
+
+	return &pb.GetLaptopResponse{
+		Laptop: laptop,
+	}, nil
+}
+
+func (server *LaptopServer) UpdateLaptop(ctx context.Context, req *pb.UpdateLaptopRequest) (*pb.UpdateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	mask := req.GetUpdateMask()
+	log.Printf("receive an update-laptop request with id: %s, mask: %v", laptop.GetId(), mask.GetPaths())
+
+	if len(mask.GetPaths()) > 0 && !mask.IsValid(laptop) {
+		return nil, status.Errorf(codes.InvalidArgument, "update mask is not valid: %v", mask.GetPaths())
+	}
+
+	for _, path := range mask.GetPaths() {
+		if path == "id" {
+			return nil, status.Errorf(codes.InvalidArgument, "laptop ID cannot be updated")
+		}
+	}
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	other, err := server.laptopStore.Find(laptop.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot find laptop: %v", err)
+	}
+
+	if other == nil {
+		return nil, status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptop.GetId())
+	}
+
+	if len(mask.GetPaths()) == 0 {
+		other = proto.Clone(laptop).(*pb.Laptop)
+	} else {
+		applyFieldMask(other, laptop, mask)
+	}
+	other.UpdatedAt = timestamppb.Now()
+
+	err = server.laptopStore.Update(other)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, repository.ErrNotFound) {
+			code = codes.NotFound
+		}
+		return nil, status.Errorf(code, "cannot update laptop in the store: %v", err)
+	}
+
+	log.Printf("updated laptop with id: %s", other.GetId())
+
+	return &pb.UpdateLaptopResponse{
+		Laptop: other,
+	}, nil
+}
+
+func (server *LaptopServer) DeleteLaptop(ctx context.Context, req *pb.DeleteLaptopRequest) (*pb.DeleteLaptopResponse, error) {
+	laptopID := req.GetId()
+	log.Printf("receive a delete-laptop request with id: %s", laptopID)
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return nil, utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
+	}
+
+	// the laptop goes last, so that a failed delete can be retried
+	err = server.deleteLaptopData(laptopID)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "%v", err))
+	}
+
+	err = server.laptopStore.Delete(laptopID)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, repository.ErrNotFound) {
+			code = codes.NotFound
+		}
+		return nil, status.Errorf(code, "cannot delete laptop from the store: %v", err)
+	}
+
+	log.Printf("deleted laptop with id: %s", laptopID)
+
+	return &pb.DeleteLaptopResponse{
+		Id: laptopID,
+	}, nil
+}
+
+// deleteLaptopData deletes the images, the rating and the reviews of a laptop.
+func (server *LaptopServer) deleteLaptopData(laptopID string) error {
+	if server.imageStore != nil {
+		images, err := server.imageStore.List(laptopID)
+		if err != nil {
+			return fmt.Errorf("cannot list images: %w", err)
+		}
+
+		for _, image := range images {
+			err := server.imageStore.Delete(image.ID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("cannot delete image %s: %w", image.ID, err)
+			}
+		}
+	}
+
+	if server.ratingStore != nil {
+		err := server.ratingStore.Delete(laptopID)
+		if err != nil {
+			return fmt.Errorf("cannot delete rating: %w", err)
+		}
+	}
+
+	if server.reviewStore != nil {
+		err := server.reviewStore.DeleteLaptop(laptopID)
+		if err != nil {
+			return fmt.Errorf("cannot delete reviews: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (server *LaptopServer) ListLaptops(ctx context.Context, req *pb.ListLaptopsRequest) (*pb.ListLaptopsResponse, error) {
+	log.Printf("receive a list-laptops request with page size: %d", req.GetPageSize())
+
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	size := pageSize(req.GetPageSize())
+
+	// fetch one extra laptop to know whether there is a next page
+	laptops, err := server.laptopStore.List(ctx, afterID, size+1)
+	if err != nil {
+		if err := utils.ContextError(ctx); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "cannot list laptops: %v", err)
+	}
+
+	res := &pb.ListLaptopsResponse{}
+	if len(laptops) > size {
+		laptops = laptops[:size]
+		res.NextPageToken = encodePageToken(laptops[size-1].GetId())
+	}
+	res.Laptops = laptops
+
+	return res, nil
+}
+
 func (server *LaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
 	filter := req.GetFilter()
-	log.Printf("receive a search-laptop request with filter: %v", filter)
+	log.Printf("receive a search-laptop request with filter: %v, sort by: %v %v", filter, req.GetSortBy(), req.GetSortOrder())
 
-	err := server.laptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
-		res := &pb.SearchLaptopResponse{Laptop: laptop}
+	cursor, err := decodeSearchCursor(req.GetPageToken(), req)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
 
-		err := stream.Send(res)
+	if inStoreOrder(req) {
+		err = server.laptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
+			err := stream.Send(&pb.SearchLaptopResponse{Laptop: laptop})
+			if err != nil {
+				return err
+			}
+
+			log.Printf("sent laptop with id: %s", laptop.GetId())
+			return nil
+		})
+		if err != nil {
+			return status.Errorf(codes.Internal, "unexpected error: %v", err)
+		}
+
+		return nil
+	}
+
+	sorter := newLaptopSorter(req, cursor)
+	err = server.laptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
+		value, err := server.sortValue(req.GetSortBy(), laptop)
 		if err != nil {
 			return err
 		}
 
-		log.Printf("sent laptop with id: %s", laptop.GetId())
+		sorter.add(laptop, value)
 		return nil
 	})
 
@@ -84,30 +372,170 @@ func (server *LaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.
 		return status.Errorf(codes.Internal, "unexpected error: %v", err)
 	}
 
+	laptops := sorter.sorted()
+	size := len(laptops)
+	if req.GetPageSize() > 0 && int(req.GetPageSize()) < size {
+		size = int(req.GetPageSize())
+	}
+
+	for i, laptop := range laptops[:size] {
+		res := &pb.SearchLaptopResponse{Laptop: laptop.laptop}
+
+		if i == size-1 && size < len(laptops) {
+			res.NextPageToken, err = encodeSearchCursor(sorter.cursor(laptop))
+			if err != nil {
+				return status.Errorf(codes.Internal, "cannot create page token: %v", err)
+			}
+		}
+
+		err := stream.Send(res)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("sent laptop with id: %s", laptop.laptop.GetId())
+	}
+
 	return nil
 }
 
-func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
-	req, err := stream.Recv()
+func (server *LaptopServer) StartImageUpload(ctx context.Context, info *pb.ImageInfo) (*pb.ImageUploadStatus, error) {
+	log.Printf("receive a start-image-upload request for laptop %s with image type %s", info.GetLaptopId(), info.GetImageType())
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := server.createUpload(info)
 	if err != nil {
-		return utils.LogError(status.Errorf(codes.Unknown, "cannot receive image info: %v", err))
+		return nil, err
 	}
 
-	laptopID := req.GetInfo().GetLaptopId()
-	imageType := req.GetInfo().GetImageType()
-	log.Printf("receive an upload-image request for laptop %s with image type %s", laptopID, imageType)
+	return &pb.ImageUploadStatus{
+		UploadId: upload.ID,
+		Offset:   uint64(upload.Offset),
+	}, nil
+}
+
+func (server *LaptopServer) GetImageUploadStatus(ctx context.Context, req *pb.GetImageUploadStatusRequest) (*pb.ImageUploadStatus, error) {
+	uploadID := req.GetUploadId()
+	log.Printf("receive a get-image-upload-status request with id: %s", uploadID)
+
+	upload, err := server.uploadStore.Find(uploadID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot find upload: %v", err)
+	}
+
+	if upload == nil {
+		return nil, status.Errorf(codes.NotFound, "upload ID %s doesn't exist", uploadID)
+	}
+
+	return &pb.ImageUploadStatus{
+		UploadId: upload.ID,
+		Offset:   uint64(upload.Offset),
+	}, nil
+}
+
+func (server *LaptopServer) createUpload(info *pb.ImageInfo) (*repository.UploadInfo, error) {
+	laptopID := info.GetLaptopId()
+
+	contentType := ""
+	if info.GetImageType() != "" {
+		contentType = declaredContentType(info.GetImageType())
+		if _, ok := repository.ImageExtension(contentType); !ok {
+			return nil, utils.LogError(status.Errorf(codes.InvalidArgument, "image type %q is not supported", info.GetImageType()))
+		}
+	}
+
+	checksum := strings.ToLower(info.GetSha256())
+	if checksum != "" && !isValidChecksum(checksum) {
+		return nil, utils.LogError(status.Errorf(codes.InvalidArgument, "sha256 is not a valid hex encoded digest"))
+	}
 
 	laptop, err := server.laptopStore.Find(laptopID)
 	if err != nil {
-		return utils.LogError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
 	}
 
 	if laptop == nil {
-		return utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
+		return nil, utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
+	}
+
+	upload, err := server.uploadStore.Create(laptopID, contentType, checksum)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot create upload: %v", err))
+	}
+
+	return upload, nil
+}
+
+// openUpload acquires the upload that the stream continues, or a new one when
+// the image info does not reference any. The caller must release it.
+func (server *LaptopServer) openUpload(info *pb.ImageInfo) (*repository.UploadInfo, error) {
+	uploadID := info.GetUploadId()
+	if uploadID == "" {
+		upload, err := server.createUpload(info)
+		if err != nil {
+			return nil, err
+		}
+		uploadID = upload.ID
+	}
+
+	upload, err := server.uploadStore.Acquire(uploadID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, utils.LogError(status.Errorf(codes.NotFound, "upload ID %s doesn't exist", uploadID))
+	}
+	if errors.Is(err, repository.ErrUploadInProgress) {
+		return nil, utils.LogError(status.Errorf(codes.Aborted, "upload ID %s is already in progress", uploadID))
+	}
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot acquire upload: %v", err))
+	}
+
+	if info.GetUploadId() == "" {
+		return upload, nil
+	}
+
+	if info.GetSha256() != "" && strings.ToLower(info.GetSha256()) != upload.Checksum {
+		server.releaseUpload(upload.ID)
+		return nil, utils.LogError(status.Errorf(codes.InvalidArgument, "sha256 doesn't match the one declared for the upload"))
+	}
+
+	if int64(info.GetOffset()) != upload.Offset {
+		server.releaseUpload(upload.ID)
+		return nil, utils.LogError(status.Errorf(codes.FailedPrecondition, "offset %d doesn't match the committed offset %d", info.GetOffset(), upload.Offset))
+	}
+
+	return upload, nil
+}
+
+func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return utils.LogError(status.Errorf(codes.Unknown, "cannot receive image info: %v", err))
 	}
 
-	imageData := bytes.Buffer{}
-	imageSize := 0
+	info := req.GetInfo()
+	log.Printf("receive an upload-image request for laptop %s with image type %s", info.GetLaptopId(), info.GetImageType())
+
+	upload, err := server.openUpload(info)
+	if err != nil {
+		return err
+	}
+	// the upload stays acquired until the image is saved, so that another
+	// stream can neither append to it nor save it twice
+	defer server.releaseUpload(upload.ID)
+
+	// uploads that were not started explicitly cannot be resumed, so they
+	// are discarded however the stream ends, while resumable ones are kept
+	// to be continued unless they are saved or can never be
+	discard := info.GetUploadId() == ""
+	defer func() {
+		if discard {
+			server.discardUpload(upload.ID)
+		}
+	}()
+	offset := upload.Offset
 
 	for {
 		if err := utils.ContextError(stream.Context()); err != nil {
@@ -131,25 +559,71 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 
 		log.Printf("receive a chunk with size: %d", size)
 
-		imageSize += size
-		if imageSize > MAX_IMAGE_SIZE {
-			return utils.LogError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, MAX_IMAGE_SIZE))
+		if offset+int64(size) > server.maxImageSize {
+			discard = true
+			return utils.LogError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", offset+int64(size), server.maxImageSize))
 		}
 
-		_, err = imageData.Write(chunk)
+		offset, err = server.uploadStore.Append(upload.ID, offset, chunk)
 		if err != nil {
 			return utils.LogError(status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
 		}
 	}
 
-	imageID, err := server.imageStore.Save(laptopID, imageType, imageData)
+	if upload.Checksum != "" {
+		checksum, err := server.uploadChecksum(upload.ID)
+		if err != nil {
+			return utils.LogError(status.Errorf(codes.Internal, "cannot compute upload checksum: %v", err))
+		}
+
+		if checksum != upload.Checksum {
+			discard = true
+			return utils.LogError(status.Errorf(codes.DataLoss, "image checksum doesn't match the declared sha256"))
+		}
+	}
+
+	contentType, err := server.sniffUpload(upload.ID)
+	if err != nil {
+		return utils.LogError(status.Errorf(codes.Internal, "cannot read upload data: %v", err))
+	}
+
+	if _, ok := repository.ImageExtension(contentType); !ok {
+		discard = true
+		return utils.LogError(status.Errorf(codes.InvalidArgument, "image content type %s is not supported", contentType))
+	}
+
+	if upload.ContentType != "" && upload.ContentType != contentType {
+		discard = true
+		return utils.LogError(status.Errorf(codes.InvalidArgument, "image content type %s doesn't match the declared %s", contentType, upload.ContentType))
+	}
+
+	var img image.Image
+	if len(server.variants) > 0 {
+		img, err = server.decodeUpload(upload.ID)
+		if err != nil {
+			discard = true
+			return utils.LogError(status.Errorf(codes.InvalidArgument, "cannot decode image: %v", err))
+		}
+	}
+
+	// saving takes the upload file, so the upload cannot be continued after
+	discard = true
+	imageID, err := server.saveUpload(upload, contentType)
 	if err != nil {
 		return utils.LogError(status.Errorf(codes.Internal, "cannot save image to the store: %v", err))
 	}
 
+	if img != nil {
+		err = server.saveVariants(imageID, img, contentType)
+		if err != nil {
+			server.imageStore.Delete(imageID)
+			return utils.LogError(status.Errorf(codes.Internal, "cannot save image variants: %v", err))
+		}
+	}
+
 	res := &pb.UploadImageResponse{
 		Id:   imageID,
-		Size: uint32(imageSize),
+		Size: uint32(offset),
 	}
 
 	err = stream.SendAndClose(res)
@@ -160,7 +634,239 @@ func (server *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServe
 	return nil
 }
 
+// saveUpload moves the upload file into the image store when the upload is
+// staged in its folder, and copies it into the store otherwise.
+func (server *LaptopServer) saveUpload(upload *repository.UploadInfo, contentType string) (string, error) {
+	fileStore, ok := server.imageStore.(repository.FileImageStore)
+	if ok && filepath.Dir(upload.Path) == filepath.Clean(fileStore.StagingFolder()) {
+		return fileStore.SaveFile(upload.LaptopID, contentType, upload.Path)
+	}
+
+	imageData, err := server.uploadStore.Open(upload.ID)
+	if err != nil {
+		return "", err
+	}
+	defer imageData.Close()
+
+	return server.imageStore.Save(upload.LaptopID, contentType, imageData)
+}
+
+func (server *LaptopServer) uploadChecksum(uploadID string) (string, error) {
+	imageData, err := server.uploadStore.Open(uploadID)
+	if err != nil {
+		return "", err
+	}
+	defer imageData.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sniffUpload detects the content type of an upload from its first bytes.
+func (server *LaptopServer) sniffUpload(uploadID string) (string, error) {
+	imageData, err := server.uploadStore.Open(uploadID)
+	if err != nil {
+		return "", err
+	}
+	defer imageData.Close()
+
+	buffer := make([]byte, SNIFF_SIZE)
+	n, err := io.ReadFull(imageData, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+func (server *LaptopServer) decodeUpload(uploadID string) (image.Image, error) {
+	imageData, err := server.uploadStore.Open(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer imageData.Close()
+
+	return decodeImage(imageData)
+}
+
+func (server *LaptopServer) saveVariants(imageID string, img image.Image, contentType string) error {
+	for _, variant := range server.variants {
+		resized := resizeImage(img, variant.Size)
+
+		imageData, variantType, err := encodeImage(resized, contentType)
+		if err != nil {
+			return fmt.Errorf("cannot encode %s variant: %w", variant.Name, err)
+		}
+
+		err = server.imageStore.SaveVariant(imageID, repository.ImageVariant{
+			Name:        variant.Name,
+			ContentType: variantType,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+		}, imageData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (server *LaptopServer) releaseUpload(uploadID string) {
+	err := server.uploadStore.Release(uploadID)
+	if err != nil {
+		log.Printf("cannot release upload %s: %v", uploadID, err)
+	}
+}
+
+func (server *LaptopServer) discardUpload(uploadID string) {
+	err := server.uploadStore.Delete(uploadID)
+	if err != nil {
+		log.Printf("cannot delete upload %s: %v", uploadID, err)
+	}
+}
+
+func (server *LaptopServer) DownloadImage(req *pb.DownloadImageRequest, stream pb.LaptopService_DownloadImageServer) error {
+	imageID := req.GetImageId()
+	variant := req.GetVariant()
+	log.Printf("receive a download-image request with id: %s, variant: %s", imageID, variant)
+
+	info, err := server.imageStore.Find(imageID)
+	if err != nil {
+		return utils.LogError(status.Errorf(codes.Internal, "cannot find image: %v", err))
+	}
+
+	if info == nil {
+		return utils.LogError(status.Errorf(codes.NotFound, "image ID %s doesn't exist", imageID))
+	}
+
+	contentType := info.ContentType
+	if variant != "" {
+		imageVariant, ok := info.Variants[variant]
+		if !ok {
+			return utils.LogError(status.Errorf(codes.NotFound, "image ID %s has no %s variant", imageID, variant))
+		}
+		contentType = imageVariant.ContentType
+	}
+
+	imageData, err := server.imageStore.Open(imageID, variant)
+	if err != nil {
+		return utils.LogError(status.Errorf(codes.Internal, "cannot open image: %v", err))
+	}
+	defer imageData.Close()
+
+	buffer := make([]byte, DOWNLOAD_CHUNK_SIZE)
+
+	for {
+		if err := utils.ContextError(stream.Context()); err != nil {
+			return err
+		}
+
+		n, err := imageData.Read(buffer)
+		if n > 0 {
+			res := &httpbody.HttpBody{
+				ContentType: contentType,
+				Data:        buffer[:n],
+			}
+
+			err := stream.Send(res)
+			if err != nil {
+				return utils.LogError(status.Errorf(codes.Unknown, "cannot send chunk data: %v", err))
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return utils.LogError(status.Errorf(codes.Internal, "cannot read image: %v", err))
+		}
+	}
+
+	log.Printf("sent image with id: %s", imageID)
+	return nil
+}
+
+func (server *LaptopServer) ListImages(ctx context.Context, req *pb.ListImagesRequest) (*pb.ListImagesResponse, error) {
+	laptopID := req.GetLaptopId()
+	log.Printf("receive a list-images request for laptop %s", laptopID)
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	images, err := server.imageStore.List(laptopID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot list images: %v", err)
+	}
+
+	res := &pb.ListImagesResponse{}
+	for _, info := range images {
+		image := &pb.Image{
+			Id:          info.ID,
+			LaptopId:    info.LaptopID,
+			ImageType:   info.Type,
+			Size:        uint32(info.Size),
+			ContentType: info.ContentType,
+			Sha256:      info.Checksum,
+		}
+
+		for _, imageVariant := range info.Variants {
+			image.Variants = append(image.Variants, &pb.ImageVariant{
+				Name:        imageVariant.Name,
+				ContentType: imageVariant.ContentType,
+				Width:       uint32(imageVariant.Width),
+				Height:      uint32(imageVariant.Height),
+				Size:        uint32(imageVariant.Size),
+			})
+		}
+
+		sort.Slice(image.Variants, func(i, j int) bool {
+			return image.Variants[i].Name < image.Variants[j].Name
+		})
+
+		res.Images = append(res.Images, image)
+	}
+
+	return res, nil
+}
+
+func (server *LaptopServer) DeleteImage(ctx context.Context, req *pb.DeleteImageRequest) (*pb.DeleteImageResponse, error) {
+	imageID := req.GetImageId()
+	log.Printf("receive a delete-image request with id: %s", imageID)
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	err := server.imageStore.Delete(imageID)
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, repository.ErrNotFound) {
+			code = codes.NotFound
+		}
+		return nil, status.Errorf(code, "cannot delete image from the store: %v", err)
+	}
+
+	log.Printf("deleted image with id: %s", imageID)
+
+	return &pb.DeleteImageResponse{
+		ImageId: imageID,
+	}, nil
+}
+
 func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) error {
+	claims, ok := ClaimsFromContext(stream.Context())
+	if !ok {
+		return utils.LogError(status.Errorf(codes.Unauthenticated, "rating a laptop requires an authenticated user"))
+	}
+
 	for {
 		err := utils.ContextError(stream.Context())
 		if err != nil {
@@ -180,7 +886,11 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 		laptopID := req.GetLaptopId()
 		score := req.GetScore()
 
-		log.Printf("receive a rate-laptop request: id = %s, score = %.2f", laptopID, score)
+		log.Printf("receive a rate-laptop request: id = %s, score = %.2f, user = %s", laptopID, score, claims.Username)
+
+		if math.IsNaN(score) || score < server.minScore || score > server.maxScore {
+			return utils.LogError(status.Errorf(codes.InvalidArgument, "score %v is outside the range [%v, %v]", score, server.minScore, server.maxScore))
+		}
 
 		found, err := server.laptopStore.Find(laptopID)
 		if err != nil {
@@ -190,7 +900,7 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 			return utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
 		}
 
-		rating, err := server.ratingStore.Add(laptopID, score)
+		rating, err := server.ratingStore.Add(laptopID, claims.Username, score)
 		if err != nil {
 			return utils.LogError(status.Errorf(codes.Internal, "cannot add rating to the store: %v", err))
 		}
@@ -198,7 +908,7 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 		res := &pb.RateLaptopResponse{
 			LaptopId:     laptopID,
 			RatedCount:   rating.Count,
-			AverageScore: rating.Sum / float64(rating.Count),
+			AverageScore: rating.Average(),
 		}
 
 		err = stream.Send(res)
@@ -209,3 +919,111 @@ func (server *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer)
 
 	return nil
 }
+
+func (server *LaptopServer) GetLaptopRating(ctx context.Context, req *pb.GetLaptopRatingRequest) (*pb.GetLaptopRatingResponse, error) {
+	laptopID := req.GetLaptopId()
+	log.Printf("receive a get-laptop-rating request with id: %s", laptopID)
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	found, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+	if found == nil {
+		return nil, utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
+	}
+
+	rating, err := server.ratingStore.Find(laptopID)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find rating: %v", err))
+	}
+	if rating == nil {
+		rating = &repository.Rating{}
+	}
+
+	res := &pb.GetLaptopRatingResponse{
+		LaptopId:     laptopID,
+		RatedCount:   rating.Count,
+		AverageScore: rating.Average(),
+	}
+
+	// every bucket of the score range is listed, even the empty ones
+	for bucket := repository.ScoreBucket(server.minScore); bucket <= repository.ScoreBucket(server.maxScore); bucket++ {
+		res.Histogram = append(res.Histogram, &pb.RatingBucket{
+			Score: int32(bucket),
+			Count: rating.Histogram[bucket],
+		})
+	}
+
+	return res, nil
+}
+
+// errEnoughLaptops stops the top-rated iteration once the limit is reached.
+var errEnoughLaptops = errors.New("enough laptops")
+
+func (server *LaptopServer) TopRatedLaptops(req *pb.TopRatedLaptopsRequest, stream pb.LaptopService_TopRatedLaptopsServer) error {
+	filter := req.GetFilter()
+	log.Printf("receive a top-rated-laptops request with filter: %v, limit: %d", filter, req.GetLimit())
+
+	laptops := make(map[string]*pb.Laptop)
+	err := server.laptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
+		laptops[laptop.GetId()] = laptop
+		return nil
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	sent := uint32(0)
+	err = server.ratingStore.Top(stream.Context(), func(laptopID string, rating *repository.Rating) error {
+		laptop, ok := laptops[laptopID]
+		if !ok || rating.Count < req.GetMinRatedCount() {
+			return nil
+		}
+
+		err := stream.Send(&pb.TopRatedLaptopsResponse{
+			Laptop:       laptop,
+			RatedCount:   rating.Count,
+			AverageScore: rating.Average(),
+			ScoreStddev:  rating.StdDev(),
+		})
+		if err != nil {
+			return err
+		}
+
+		sent++
+		if sent == req.GetLimit() {
+			return errEnoughLaptops
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnoughLaptops) {
+		return status.Errorf(codes.Internal, "unexpected error: %v", err)
+	}
+
+	return nil
+}
+
+// declaredContentType turns the image type declared by a client, either a
+// file extension such as "jpg" or a MIME type, into a MIME type.
+func declaredContentType(imageType string) string {
+	imageType = strings.ToLower(strings.TrimPrefix(imageType, "."))
+	if strings.Contains(imageType, "/") {
+		return imageType
+	}
+
+	if imageType == "jpg" {
+		imageType = "jpeg"
+	}
+
+	return "image/" + imageType
+}
+
+func isValidChecksum(checksum string) bool {
+	digest, err := hex.DecodeString(checksum)
+	return err == nil && len(digest) == sha256.Size
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+)
+
+type sortedLaptop struct {
+	laptop *pb.Laptop
+	value  float64
+}
+
+// laptopSorter orders laptops by a sort key, using the laptop ID to break ties
+// so that every laptop has a stable position a cursor can point to. It only
+// keeps the laptops positioned after the cursor, and with a page size only the
+// first of them, one more than the page size to tell whether more are left.
+// Without a page size every matching laptop is held in memory.
+type laptopSorter struct {
+	sortBy    pb.SearchLaptopRequest_SortBy
+	sortOrder pb.SearchLaptopRequest_SortOrder
+	after     *searchCursor
+	limit     int
+	laptops   []sortedLaptop
+}
+
+func newLaptopSorter(req *pb.SearchLaptopRequest, after *searchCursor) *laptopSorter {
+	sorter := &laptopSorter{
+		sortBy:    req.GetSortBy(),
+		sortOrder: req.GetSortOrder(),
+		after:     after,
+	}
+
+	if req.GetPageSize() > 0 {
+		sorter.limit = int(req.GetPageSize()) + 1
+	}
+
+	return sorter
+}
+
+// inStoreOrder reports whether the laptops of the search can be sent as they
+// are found, because the request takes the ID order the store finds them in,
+// without paging.
+func inStoreOrder(req *pb.SearchLaptopRequest) bool {
+	return req.GetSortBy() == pb.SearchLaptopRequest_ID &&
+		req.GetSortOrder() == pb.SearchLaptopRequest_ASC &&
+		req.GetPageSize() == 0 &&
+		req.GetPageToken() == ""
+}
+
+func (server *LaptopServer) sortValue(sortBy pb.SearchLaptopRequest_SortBy, laptop *pb.Laptop) (float64, error) {
+	switch sortBy {
+	case pb.SearchLaptopRequest_PRICE:
+		return laptop.GetPriceUsd(), nil
+	case pb.SearchLaptopRequest_RELEASE_YEAR:
+		return float64(laptop.GetReleaseYear()), nil
+	case pb.SearchLaptopRequest_CPU_GHZ:
+		return laptop.GetCpu().GetMinGhz(), nil
+	case pb.SearchLaptopRequest_RAM:
+		return float64(memoryBits(laptop.GetRam())), nil
+	case pb.SearchLaptopRequest_AVERAGE_RATING:
+		if server.ratingStore == nil {
+			return 0, nil
+		}
+
+		rating, err := server.ratingStore.Find(laptop.GetId())
+		if err != nil {
+			return 0, fmt.Errorf("cannot find rating: %w", err)
+		}
+		return rating.Average(), nil
+	default:
+		return 0, nil
+	}
+}
+
+func (sorter *laptopSorter) add(laptop *pb.Laptop, value float64) {
+	if sorter.after != nil && !sorter.less(sorter.after.Value, sorter.after.ID, value, laptop.GetId()) {
+		return
+	}
+
+	if sorter.limit == 0 {
+		sorter.laptops = append(sorter.laptops, sortedLaptop{laptop: laptop, value: value})
+		return
+	}
+
+	// the kept laptops are a heap with the last of them on top, which is
+	// dropped when there are too many
+	heap.Push(sorter, sortedLaptop{laptop: laptop, value: value})
+	if sorter.Len() > sorter.limit {
+		heap.Pop(sorter)
+	}
+}
+
+func (sorter *laptopSorter) Len() int {
+	return len(sorter.laptops)
+}
+
+func (sorter *laptopSorter) Less(i, j int) bool {
+	laptops := sorter.laptops
+	return sorter.less(laptops[j].value, laptops[j].laptop.GetId(), laptops[i].value, laptops[i].laptop.GetId())
+}
+
+func (sorter *laptopSorter) Swap(i, j int) {
+	sorter.laptops[i], sorter.laptops[j] = sorter.laptops[j], sorter.laptops[i]
+}
+
+func (sorter *laptopSorter) Push(x interface{}) {
+	sorter.laptops = append(sorter.laptops, x.(sortedLaptop))
+}
+
+func (sorter *laptopSorter) Pop() interface{} {
+	last := sorter.laptops[len(sorter.laptops)-1]
+	sorter.laptops = sorter.laptops[:len(sorter.laptops)-1]
+	return last
+}
+
+func (sorter *laptopSorter) less(value1 float64, id1 string, value2 float64, id2 string) bool {
+	if sorter.sortOrder == pb.SearchLaptopRequest_DESC {
+		value1, id1, value2, id2 = value2, id2, value1, id1
+	}
+
+	if value1 != value2 {
+		return value1 < value2
+	}
+
+	return id1 < id2
+}
+
+// sorted returns the kept laptops in order.
+func (sorter *laptopSorter) sorted() []sortedLaptop {
+	laptops := sorter.laptops
+	sort.Slice(laptops, func(i, j int) bool {
+		return sorter.less(laptops[i].value, laptops[i].laptop.GetId(), laptops[j].value, laptops[j].laptop.GetId())
+	})
+
+	return laptops
+}
+
+func (sorter *laptopSorter) cursor(laptop sortedLaptop) *searchCursor {
+	return &searchCursor{
+		SortBy:    sorter.sortBy,
+		SortOrder: sorter.sortOrder,
+		Value:     laptop.value,
+		ID:        laptop.laptop.GetId(),
+	}
+}
+
+// memoryBits returns the size of the memory in bits, so sizes in different
+// units compare.
+func memoryBits(memory *pb.Memory) uint64 {
+	value := memory.GetValue()
+
+	switch memory.GetUnit() {
+	case pb.Memory_BIT:
+		return value
+	case pb.Memory_BYTE:
+		return value << 3
+	case pb.Memory_KILOBYTE:
+		return value << 13
+	case pb.Memory_MEGABYTE:
+		return value << 23
+	case pb.Memory_GIGABYTE:
+		return value << 33
+	case pb.Memory_TERABYTE:
+		return value << 43
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	DEFAULT_MAX_USER_LOGIN_FAILURES = 5
+	DEFAULT_MAX_IP_LOGIN_FAILURES   = 20
+	DEFAULT_LOGIN_LOCKOUT           = 30 * time.Second
+	DEFAULT_MAX_LOGIN_LOCKOUT       = 15 * time.Minute
+	DEFAULT_LOGIN_FAILURE_WINDOW    = 15 * time.Minute
+)
+
+// LoginLimiter slows down password guessing. It counts the failed logins of
+// every username and of every client IP, and once there are too many of them
+// locks the username or the IP out, twice as long after each further failure.
+type LoginLimiter struct {
+	mutex           sync.Mutex
+	attempts        map[string]*loginAttempts
+	maxUserFailures int
+	maxIPFailures   int
+	lockout         time.Duration
+	maxLockout      time.Duration
+	failureWindow   time.Duration
+	pruning         *time.Timer
+}
+
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type LoginLimiterOption func(*LoginLimiter)
+
+// WithMaxLoginFailures sets how many failed logins of a username and of an IP
+// are allowed before locking them out. Zero disables the limit.
+func WithMaxLoginFailures(perUser int, perIP int) LoginLimiterOption {
+	return func(limiter *LoginLimiter) {
+		limiter.maxUserFailures = perUser
+		limiter.maxIPFailures = perIP
+	}
+}
+
+// WithLoginLockout sets how long the first lockout lasts, and how long the
+// doubled lockouts can get.
+func WithLoginLockout(lockout time.Duration, maxLockout time.Duration) LoginLimiterOption {
+	return func(limiter *LoginLimiter) {
+		limiter.lockout = lockout
+		limiter.maxLockout = maxLockout
+	}
+}
+
+// WithLoginFailureWindow sets how long failed logins are remembered after the
+// last one.
+func WithLoginFailureWindow(window time.Duration) LoginLimiterOption {
+	return func(limiter *LoginLimiter) {
+		limiter.failureWindow = window
+	}
+}
+
+func NewLoginLimiter(opts ...LoginLimiterOption) *LoginLimiter {
+	limiter := &LoginLimiter{
+		attempts:        make(map[string]*loginAttempts),
+		maxUserFailures: DEFAULT_MAX_USER_LOGIN_FAILURES,
+		maxIPFailures:   DEFAULT_MAX_IP_LOGIN_FAILURES,
+		lockout:         DEFAULT_LOGIN_LOCKOUT,
+		maxLockout:      DEFAULT_MAX_LOGIN_LOCKOUT,
+		failureWindow:   DEFAULT_LOGIN_FAILURE_WINDOW,
+	}
+
+	for _, opt := range opts {
+		opt(limiter)
+	}
+
+	return limiter
+}
+
+// Check returns how long the username or the IP is still locked out, or zero
+// if both may log in.
+func (limiter *LoginLimiter) Check(username string, ip string) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range loginLimiterKeys(username, ip) {
+		attempts, ok := limiter.attempts[key]
+		if ok && attempts.lockedUntil.Sub(now) > wait {
+			wait = attempts.lockedUntil.Sub(now)
+		}
+	}
+
+	return wait
+}
+
+// Fail records a failed login, and returns how long the username or the IP
+// is locked out because of it, if at all.
+func (limiter *LoginLimiter) Fail(username string, ip string) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range loginLimiterKeys(username, ip) {
+		maxFailures := limiter.maxUserFailures
+		if strings.HasPrefix(key, "ip:") {
+			maxFailures = limiter.maxIPFailures
+		}
+		if maxFailures <= 0 {
+			continue
+		}
+
+		attempts, ok := limiter.attempts[key]
+		if !ok || attempts.expired(now, limiter.failureWindow) {
+			attempts = &loginAttempts{}
+			limiter.attempts[key] = attempts
+		}
+
+		attempts.failures++
+		attempts.lastFailure = now
+		if attempts.failures < maxFailures {
+			continue
+		}
+
+		lockout := limiter.lockoutAfter(attempts.failures - maxFailures)
+		attempts.lockedUntil = now.Add(lockout)
+		if lockout > wait {
+			wait = lockout
+		}
+	}
+
+	// the timer only runs while there are failures to forget
+	if limiter.pruning == nil && len(limiter.attempts) > 0 {
+		limiter.pruning = time.AfterFunc(limiter.failureWindow, limiter.prune)
+	}
+
+	return wait
+}
+
+// Succeed forgets the failed logins of the username. The ones of the IP are
+// kept, otherwise logging into an account of their own would let an attacker
+// keep guessing the passwords of others.
+func (limiter *LoginLimiter) Succeed(username string) {
+	limiter.Unlock(username, "")
+}
+
+// Unlock forgets the failed logins of the username and of the IP, which ends
+// their lockouts. Either of them can be empty.
+func (limiter *LoginLimiter) Unlock(username string, ip string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if username != "" {
+		delete(limiter.attempts, "user:"+username)
+	}
+	if ip != "" {
+		delete(limiter.attempts, "ip:"+ip)
+	}
+}
+
+// prune forgets the failed logins that are no longer locked out nor within
+// the failure window.
+func (limiter *LoginLimiter) prune() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	for key, attempts := range limiter.attempts {
+		if attempts.expired(now, limiter.failureWindow) {
+			delete(limiter.attempts, key)
+		}
+	}
+
+	if len(limiter.attempts) == 0 {
+		limiter.pruning = nil
+		return
+	}
+
+	limiter.pruning.Reset(limiter.failureWindow)
+}
+
+func (limiter *LoginLimiter) lockoutAfter(extraFailures int) time.Duration {
+	lockout := limiter.lockout
+	for i := 0; i < extraFailures && lockout < limiter.maxLockout; i++ {
+		lockout *= 2
+	}
+
+	if lockout > limiter.maxLockout {
+		lockout = limiter.maxLockout
+	}
+
+	return lockout
+}
+
+func (attempts *loginAttempts) expired(now time.Time, failureWindow time.Duration) bool {
+	return attempts.lockedUntil.Before(now) && now.Sub(attempts.lastFailure) > failureWindow
+}
+
+func loginLimiterKeys(username string, ip string) []string {
+	keys := []string{"user:" + username}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+
+	return keys
+}
+
+// peerIP returns the IP of the client. The REST gateway calls the server
+// through an in-process connection, so the IP of its clients is the last one
+// of the X-Forwarded-For header, which the gateway appends it to.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get("x-forwarded-for")
+	if len(forwarded) == 0 {
+		return ""
+	}
+
+	ips := strings.Split(forwarded[len(forwarded)-1], ",")
+	return strings.TrimSpace(ips[len(ips)-1])
+}
@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	t.Parallel()
+
+	limiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(2, 0),
+		service.WithLoginLockout(time.Minute, 3*time.Minute),
+	)
+
+	testCases := []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, lockout := range testCases {
+		require.Equal(t, lockout, limiter.Fail("alice", "10.0.0.1"), "failure %d", i+1)
+	}
+
+	require.Greater(t, limiter.Check("alice", "10.0.0.2"), 2*time.Minute)
+	require.Zero(t, limiter.Check("bob", "10.0.0.1"))
+
+	limiter.Unlock("alice", "")
+	require.Zero(t, limiter.Check("alice", "10.0.0.1"))
+	require.Zero(t, limiter.Fail("alice", "10.0.0.1"))
+}
+
+func TestLoginLimiterIP(t *testing.T) {
+	t.Parallel()
+
+	limiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(5, 3),
+		service.WithLoginLockout(time.Minute, time.Hour),
+	)
+
+	require.Zero(t, limiter.Fail("alice", "10.0.0.1"))
+	require.Zero(t, limiter.Fail("bob", "10.0.0.1"))
+
+	// logging into an account of its own doesn't reset the failures of the IP
+	limiter.Succeed("carol")
+	require.Equal(t, time.Minute, limiter.Fail("carol", "10.0.0.1"))
+
+	require.Greater(t, limiter.Check("dave", "10.0.0.1"), time.Duration(0))
+	require.Zero(t, limiter.Check("dave", "10.0.0.2"))
+	require.Zero(t, limiter.Check("alice", ""))
+
+	limiter.Unlock("", "10.0.0.1")
+	require.Zero(t, limiter.Check("dave", "10.0.0.1"))
+	require.Zero(t, limiter.Fail("dave", "10.0.0.1"))
+}
+
+func TestLoginLimiterFailureWindow(t *testing.T) {
+	t.Parallel()
+
+	limiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(2, 0),
+		service.WithLoginFailureWindow(50*time.Millisecond),
+	)
+
+	require.Zero(t, limiter.Fail("alice", ""))
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, limiter.Fail("alice", ""))
+	require.Equal(t, service.DEFAULT_LOGIN_LOCKOUT, limiter.Fail("alice", ""))
+}
+
+func TestLoginLimiterLockoutOutlivesWindow(t *testing.T) {
+	t.Parallel()
+
+	limiter := service.NewLoginLimiter(
+		service.WithMaxLoginFailures(1, 0),
+		service.WithLoginLockout(200*time.Millisecond, time.Second),
+		service.WithLoginFailureWindow(20*time.Millisecond),
+	)
+
+	require.Equal(t, 200*time.Millisecond, limiter.Fail("alice", ""))
+
+	// pruning the expired failures keeps the lockouts that still run
+	time.Sleep(100 * time.Millisecond)
+	require.Greater(t, limiter.Check("alice", ""), time.Duration(0))
+
+	time.Sleep(150 * time.Millisecond)
+	require.Zero(t, limiter.Check("alice", ""))
+	require.Equal(t, 200*time.Millisecond, limiter.Fail("alice", ""))
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+func pageSize(requested uint32) int {
+	if requested == 0 {
+		return defaultPageSize
+	}
+
+	if requested > maxPageSize {
+		return maxPageSize
+	}
+
+	return int(requested)
+}
+
+func encodePageToken(lastID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(lastID))
+}
+
+func decodePageToken(token string) (string, error) {
+	lastID, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("invalid page token: %w", err)
+	}
+
+	return string(lastID), nil
+}
+
+type searchCursor struct {
+	SortBy    pb.SearchLaptopRequest_SortBy    `json:"sort_by"`
+	SortOrder pb.SearchLaptopRequest_SortOrder `json:"sort_order"`
+	Value     float64                          `json:"value"`
+	ID        string                           `json:"id"`
+}
+
+func encodeSearchCursor(cursor *searchCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeSearchCursor returns nil for an empty token, which means the first page.
+func decodeSearchCursor(token string, req *pb.SearchLaptopRequest) (*searchCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	cursor := &searchCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	if cursor.SortBy != req.GetSortBy() || cursor.SortOrder != req.GetSortOrder() {
+		return nil, fmt.Errorf("page token does not match the requested sort")
+	}
+
+	return cursor, nil
+}
+
+func encodeReviewCursor(cursor *repository.ReviewCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode page token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeReviewCursor returns nil for an empty token, which means the first page.
+func decodeReviewCursor(token string) (*repository.ReviewCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	cursor := &repository.ReviewCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	return cursor, nil
+}
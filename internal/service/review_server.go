@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/repository"
+	"github.com/caiofernandes00/playing-with-golang/grpc/internal/utils"
+	"github.com/caiofernandes00/playing-with-golang/grpc/pkg/proto/pb"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PERMISSION_REVIEW_MODERATE is the permission of the access policy needed to
+// list hidden reviews.
+const PERMISSION_REVIEW_MODERATE = "review.moderate"
+
+const (
+	MAX_REVIEW_TITLE_LENGTH = 120
+	MAX_REVIEW_TEXT_LENGTH  = 5000
+	MAX_REVIEW_POINTS       = 10
+	MAX_REVIEW_POINT_LENGTH = 200
+)
+
+// PermissionChecker reports whether a role has a permission of the access
+// policy.
+type PermissionChecker func(role string, permission string) bool
+
+type ReviewServer struct {
+	reviewStore   repository.ReviewStore
+	laptopStore   repository.LaptopStore
+	hasPermission PermissionChecker
+}
+
+type ReviewServerOption func(*ReviewServer)
+
+// WithPermissionChecker sets how the permissions that ListReviews needs for
+// hidden reviews are checked. Without it, nobody can list hidden reviews.
+func WithPermissionChecker(hasPermission PermissionChecker) ReviewServerOption {
+	return func(server *ReviewServer) {
+		server.hasPermission = hasPermission
+	}
+}
+
+func NewReviewServer(reviewStore repository.ReviewStore, laptopStore repository.LaptopStore, opts ...ReviewServerOption) *ReviewServer {
+	server := &ReviewServer{
+		reviewStore: reviewStore,
+		laptopStore: laptopStore,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server
+}
+
+func (server *ReviewServer) CreateReview(ctx context.Context, req *pb.CreateReviewRequest) (*pb.CreateReviewResponse, error) {
+	laptopID := req.GetLaptopId()
+	log.Printf("receive a create-review request for laptop: %s", laptopID)
+
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, utils.LogError(status.Errorf(codes.Unauthenticated, "writing a review requires an authenticated user"))
+	}
+
+	err := validateReview(req)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.InvalidArgument, "%v", err))
+	}
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	laptop, err := server.laptopStore.Find(laptopID)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot find laptop: %v", err))
+	}
+	if laptop == nil {
+		return nil, utils.LogError(status.Errorf(codes.NotFound, "laptop ID %s doesn't exist", laptopID))
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot generate a new review ID: %v", err))
+	}
+
+	review := &pb.Review{
+		Id:        id.String(),
+		LaptopId:  laptopID,
+		Author:    claims.Username,
+		Title:     strings.TrimSpace(req.GetTitle()),
+		Text:      strings.TrimSpace(req.GetText()),
+		Pros:      trimPoints(req.GetPros()),
+		Cons:      trimPoints(req.GetCons()),
+		CreatedAt: timestamppb.Now(),
+	}
+
+	err = server.reviewStore.Save(review)
+	if err != nil {
+		return nil, utils.LogError(status.Errorf(codes.Internal, "cannot save review to the store: %v", err))
+	}
+
+	log.Printf("saved review with id: %s", review.GetId())
+
+	return &pb.CreateReviewResponse{
+		Review: review,
+	}, nil
+}
+
+func (server *ReviewServer) ListReviews(ctx context.Context, req *pb.ListReviewsRequest) (*pb.ListReviewsResponse, error) {
+	log.Printf("receive a list-reviews request for laptop: %s, page size: %d, include hidden: %t", req.GetLaptopId(), req.GetPageSize(), req.GetIncludeHidden())
+
+	if req.GetIncludeHidden() {
+		claims, ok := ClaimsFromContext(ctx)
+		if !ok {
+			return nil, utils.LogError(status.Errorf(codes.Unauthenticated, "listing hidden reviews requires an authenticated user"))
+		}
+
+		if server.hasPermission == nil || !server.hasPermission(claims.Role, PERMISSION_REVIEW_MODERATE) {
+			return nil, utils.LogError(status.Errorf(codes.PermissionDenied, "no permission to list hidden reviews"))
+		}
+	}
+
+	after, err := decodeReviewCursor(req.GetPageToken())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	size := pageSize(req.GetPageSize())
+
+	// fetch one extra review to know whether there is a next page
+	reviews, err := server.reviewStore.List(ctx, req.GetLaptopId(), after, size+1, req.GetIncludeHidden())
+	if err != nil {
+		if err := utils.ContextError(ctx); err != nil {
+			return nil, err
+		}
+		return nil, status.Errorf(codes.Internal, "cannot list reviews: %v", err)
+	}
+
+	res := &pb.ListReviewsResponse{}
+	if len(reviews) > size {
+		reviews = reviews[:size]
+		res.NextPageToken, err = encodeReviewCursor(repository.NewReviewCursor(reviews[size-1]))
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "cannot create page token: %v", err)
+		}
+	}
+	res.Reviews = reviews
+
+	return res, nil
+}
+
+func (server *ReviewServer) ModerateReview(ctx context.Context, req *pb.ModerateReviewRequest) (*pb.ModerateReviewResponse, error) {
+	reviewID := req.GetId()
+	log.Printf("receive a moderate-review request with id: %s, hidden: %t", reviewID, req.GetHidden())
+
+	if err := utils.ContextError(ctx); err != nil {
+		return nil, err
+	}
+
+	review, err := server.reviewStore.SetHidden(reviewID, req.GetHidden())
+	if err != nil {
+		code := codes.Internal
+		if errors.Is(err, repository.ErrNotFound) {
+			code = codes.NotFound
+		}
+		return nil, status.Errorf(code, "cannot moderate review: %v", err)
+	}
+
+	return &pb.ModerateReviewResponse{
+		Review: review,
+	}, nil
+}
+
+func validateReview(req *pb.CreateReviewRequest) error {
+	title := strings.TrimSpace(req.GetTitle())
+	if title == "" {
+		return errors.New("review title is required")
+	}
+	if utf8.RuneCountInString(title) > MAX_REVIEW_TITLE_LENGTH {
+		return errors.New("review title is too long")
+	}
+
+	text := strings.TrimSpace(req.GetText())
+	if text == "" {
+		return errors.New("review text is required")
+	}
+	if utf8.RuneCountInString(text) > MAX_REVIEW_TEXT_LENGTH {
+		return errors.New("review text is too long")
+	}
+
+	for _, points := range [][]string{req.GetPros(), req.GetCons()} {
+		if len(points) > MAX_REVIEW_POINTS {
+			return errors.New("review has too many pros or cons")
+		}
+
+		for _, point := range trimPoints(points) {
+			if point == "" || utf8.RuneCountInString(point) > MAX_REVIEW_POINT_LENGTH {
+				return errors.New("review pros and cons must be non-empty and short")
+			}
+		}
+	}
+
+	return nil
+}
+
+func trimPoints(points []string) []string {
+	trimmed := make([]string, 0, len(points))
+	for _, point := range points {
+		trimmed = append(trimmed, strings.TrimSpace(point))
+	}
+
+	return trimmed
+}
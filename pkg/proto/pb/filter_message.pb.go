@@ -20,21 +20,217 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// Unset bounds of a range are not checked.
+type DoubleRange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Min *float64 `protobuf:"fixed64,1,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max *float64 `protobuf:"fixed64,2,opt,name=max,proto3,oneof" json:"max,omitempty"`
+}
+
+func (x *DoubleRange) Reset() {
+	*x = DoubleRange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_filter_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DoubleRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DoubleRange) ProtoMessage() {}
+
+func (x *DoubleRange) ProtoReflect() protoreflect.Message {
+	mi := &file_filter_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DoubleRange.ProtoReflect.Descriptor instead.
+func (*DoubleRange) Descriptor() ([]byte, []int) {
+	return file_filter_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DoubleRange) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *DoubleRange) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+type UInt32Range struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Min *uint32 `protobuf:"varint,1,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max *uint32 `protobuf:"varint,2,opt,name=max,proto3,oneof" json:"max,omitempty"`
+}
+
+func (x *UInt32Range) Reset() {
+	*x = UInt32Range{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_filter_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UInt32Range) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UInt32Range) ProtoMessage() {}
+
+func (x *UInt32Range) ProtoReflect() protoreflect.Message {
+	mi := &file_filter_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UInt32Range.ProtoReflect.Descriptor instead.
+func (*UInt32Range) Descriptor() ([]byte, []int) {
+	return file_filter_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UInt32Range) GetMin() uint32 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *UInt32Range) GetMax() uint32 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+type MemoryRange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Min *Memory `protobuf:"bytes,1,opt,name=min,proto3" json:"min,omitempty"`
+	Max *Memory `protobuf:"bytes,2,opt,name=max,proto3" json:"max,omitempty"`
+}
+
+func (x *MemoryRange) Reset() {
+	*x = MemoryRange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_filter_message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MemoryRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MemoryRange) ProtoMessage() {}
+
+func (x *MemoryRange) ProtoReflect() protoreflect.Message {
+	mi := &file_filter_message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MemoryRange.ProtoReflect.Descriptor instead.
+func (*MemoryRange) Descriptor() ([]byte, []int) {
+	return file_filter_message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MemoryRange) GetMin() *Memory {
+	if x != nil {
+		return x.Min
+	}
+	return nil
+}
+
+func (x *MemoryRange) GetMax() *Memory {
+	if x != nil {
+		return x.Max
+	}
+	return nil
+}
+
+// Every field left empty is ignored. Repeated fields match when the laptop
+// value is one of the given values. The GPU fields match when a single GPU of
+// the laptop meets all of them.
 type Filter struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	MaxPriceUsd float64 `protobuf:"fixed64,1,opt,name=max_price_usd,json=maxPriceUsd,proto3" json:"max_price_usd,omitempty"`
-	MinCpuCores uint32  `protobuf:"varint,2,opt,name=min_cpu_cores,json=minCpuCores,proto3" json:"min_cpu_cores,omitempty"`
-	MinCpuGhz   float64 `protobuf:"fixed64,3,opt,name=min_cpu_ghz,json=minCpuGhz,proto3" json:"min_cpu_ghz,omitempty"`
-	MinRam      *Memory `protobuf:"bytes,4,opt,name=min_ram,json=minRam,proto3" json:"min_ram,omitempty"`
+	// Zero means no limit. Prefer price_usd.
+	MaxPriceUsd     float64           `protobuf:"fixed64,1,opt,name=max_price_usd,json=maxPriceUsd,proto3" json:"max_price_usd,omitempty"`
+	MinCpuCores     uint32            `protobuf:"varint,2,opt,name=min_cpu_cores,json=minCpuCores,proto3" json:"min_cpu_cores,omitempty"`
+	MinCpuGhz       float64           `protobuf:"fixed64,3,opt,name=min_cpu_ghz,json=minCpuGhz,proto3" json:"min_cpu_ghz,omitempty"`
+	MinRam          *Memory           `protobuf:"bytes,4,opt,name=min_ram,json=minRam,proto3" json:"min_ram,omitempty"`
+	Brands          []string          `protobuf:"bytes,5,rep,name=brands,proto3" json:"brands,omitempty"`
+	NameContains    string            `protobuf:"bytes,6,opt,name=name_contains,json=nameContains,proto3" json:"name_contains,omitempty"`
+	PriceUsd        *DoubleRange      `protobuf:"bytes,7,opt,name=price_usd,json=priceUsd,proto3" json:"price_usd,omitempty"`
+	ReleaseYear     *UInt32Range      `protobuf:"bytes,8,opt,name=release_year,json=releaseYear,proto3" json:"release_year,omitempty"`
+	CpuBrands       []string          `protobuf:"bytes,9,rep,name=cpu_brands,json=cpuBrands,proto3" json:"cpu_brands,omitempty"`
+	CpuCores        *UInt32Range      `protobuf:"bytes,10,opt,name=cpu_cores,json=cpuCores,proto3" json:"cpu_cores,omitempty"`
+	CpuThreads      *UInt32Range      `protobuf:"bytes,11,opt,name=cpu_threads,json=cpuThreads,proto3" json:"cpu_threads,omitempty"`
+	CpuMinGhz       *DoubleRange      `protobuf:"bytes,12,opt,name=cpu_min_ghz,json=cpuMinGhz,proto3" json:"cpu_min_ghz,omitempty"`
+	CpuMaxGhz       *DoubleRange      `protobuf:"bytes,13,opt,name=cpu_max_ghz,json=cpuMaxGhz,proto3" json:"cpu_max_ghz,omitempty"`
+	Ram             *MemoryRange      `protobuf:"bytes,14,opt,name=ram,proto3" json:"ram,omitempty"`
+	HasGpu          *bool             `protobuf:"varint,15,opt,name=has_gpu,json=hasGpu,proto3,oneof" json:"has_gpu,omitempty"`
+	GpuBrands       []string          `protobuf:"bytes,16,rep,name=gpu_brands,json=gpuBrands,proto3" json:"gpu_brands,omitempty"`
+	GpuMemory       *MemoryRange      `protobuf:"bytes,17,opt,name=gpu_memory,json=gpuMemory,proto3" json:"gpu_memory,omitempty"`
+	StorageDrivers  []Storage_Driver  `protobuf:"varint,18,rep,packed,name=storage_drivers,json=storageDrivers,proto3,enum=playingwithgolang.grpc.Storage_Driver" json:"storage_drivers,omitempty"`
+	StorageCapacity *MemoryRange      `protobuf:"bytes,19,opt,name=storage_capacity,json=storageCapacity,proto3" json:"storage_capacity,omitempty"`
+	ScreenSizeInch  *DoubleRange      `protobuf:"bytes,20,opt,name=screen_size_inch,json=screenSizeInch,proto3" json:"screen_size_inch,omitempty"`
+	ScreenWidth     *UInt32Range      `protobuf:"bytes,21,opt,name=screen_width,json=screenWidth,proto3" json:"screen_width,omitempty"`
+	ScreenHeight    *UInt32Range      `protobuf:"bytes,22,opt,name=screen_height,json=screenHeight,proto3" json:"screen_height,omitempty"`
+	ScreenPainels   []Screen_Painel   `protobuf:"varint,23,rep,packed,name=screen_painels,json=screenPainels,proto3,enum=playingwithgolang.grpc.Screen_Painel" json:"screen_painels,omitempty"`
+	KeyboardLayouts []Keyboard_Layout `protobuf:"varint,24,rep,packed,name=keyboard_layouts,json=keyboardLayouts,proto3,enum=playingwithgolang.grpc.Keyboard_Layout" json:"keyboard_layouts,omitempty"`
+	KeyboardBacklit *bool             `protobuf:"varint,25,opt,name=keyboard_backlit,json=keyboardBacklit,proto3,oneof" json:"keyboard_backlit,omitempty"`
+	WeightKg        *DoubleRange      `protobuf:"bytes,26,opt,name=weight_kg,json=weightKg,proto3" json:"weight_kg,omitempty"`
+	CpuNameContains string            `protobuf:"bytes,27,opt,name=cpu_name_contains,json=cpuNameContains,proto3" json:"cpu_name_contains,omitempty"`
+	GpuNameContains string            `protobuf:"bytes,28,opt,name=gpu_name_contains,json=gpuNameContains,proto3" json:"gpu_name_contains,omitempty"`
+	GpuMinGhz       *DoubleRange      `protobuf:"bytes,29,opt,name=gpu_min_ghz,json=gpuMinGhz,proto3" json:"gpu_min_ghz,omitempty"`
+	GpuMaxGhz       *DoubleRange      `protobuf:"bytes,30,opt,name=gpu_max_ghz,json=gpuMaxGhz,proto3" json:"gpu_max_ghz,omitempty"`
 }
 
 func (x *Filter) Reset() {
 	*x = Filter{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_filter_message_proto_msgTypes[0]
+		mi := &file_filter_message_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -47,7 +243,7 @@ func (x *Filter) String() string {
 func (*Filter) ProtoMessage() {}
 
 func (x *Filter) ProtoReflect() protoreflect.Message {
-	mi := &file_filter_message_proto_msgTypes[0]
+	mi := &file_filter_message_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -60,7 +256,7 @@ func (x *Filter) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Filter.ProtoReflect.Descriptor instead.
 func (*Filter) Descriptor() ([]byte, []int) {
-	return file_filter_message_proto_rawDescGZIP(), []int{0}
+	return file_filter_message_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Filter) GetMaxPriceUsd() float64 {
@@ -91,6 +287,188 @@ func (x *Filter) GetMinRam() *Memory {
 	return nil
 }
 
+func (x *Filter) GetBrands() []string {
+	if x != nil {
+		return x.Brands
+	}
+	return nil
+}
+
+func (x *Filter) GetNameContains() string {
+	if x != nil {
+		return x.NameContains
+	}
+	return ""
+}
+
+func (x *Filter) GetPriceUsd() *DoubleRange {
+	if x != nil {
+		return x.PriceUsd
+	}
+	return nil
+}
+
+func (x *Filter) GetReleaseYear() *UInt32Range {
+	if x != nil {
+		return x.ReleaseYear
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuBrands() []string {
+	if x != nil {
+		return x.CpuBrands
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuCores() *UInt32Range {
+	if x != nil {
+		return x.CpuCores
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuThreads() *UInt32Range {
+	if x != nil {
+		return x.CpuThreads
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuMinGhz() *DoubleRange {
+	if x != nil {
+		return x.CpuMinGhz
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuMaxGhz() *DoubleRange {
+	if x != nil {
+		return x.CpuMaxGhz
+	}
+	return nil
+}
+
+func (x *Filter) GetRam() *MemoryRange {
+	if x != nil {
+		return x.Ram
+	}
+	return nil
+}
+
+func (x *Filter) GetHasGpu() bool {
+	if x != nil && x.HasGpu != nil {
+		return *x.HasGpu
+	}
+	return false
+}
+
+func (x *Filter) GetGpuBrands() []string {
+	if x != nil {
+		return x.GpuBrands
+	}
+	return nil
+}
+
+func (x *Filter) GetGpuMemory() *MemoryRange {
+	if x != nil {
+		return x.GpuMemory
+	}
+	return nil
+}
+
+func (x *Filter) GetStorageDrivers() []Storage_Driver {
+	if x != nil {
+		return x.StorageDrivers
+	}
+	return nil
+}
+
+func (x *Filter) GetStorageCapacity() *MemoryRange {
+	if x != nil {
+		return x.StorageCapacity
+	}
+	return nil
+}
+
+func (x *Filter) GetScreenSizeInch() *DoubleRange {
+	if x != nil {
+		return x.ScreenSizeInch
+	}
+	return nil
+}
+
+func (x *Filter) GetScreenWidth() *UInt32Range {
+	if x != nil {
+		return x.ScreenWidth
+	}
+	return nil
+}
+
+func (x *Filter) GetScreenHeight() *UInt32Range {
+	if x != nil {
+		return x.ScreenHeight
+	}
+	return nil
+}
+
+func (x *Filter) GetScreenPainels() []Screen_Painel {
+	if x != nil {
+		return x.ScreenPainels
+	}
+	return nil
+}
+
+func (x *Filter) GetKeyboardLayouts() []Keyboard_Layout {
+	if x != nil {
+		return x.KeyboardLayouts
+	}
+	return nil
+}
+
+func (x *Filter) GetKeyboardBacklit() bool {
+	if x != nil && x.KeyboardBacklit != nil {
+		return *x.KeyboardBacklit
+	}
+	return false
+}
+
+func (x *Filter) GetWeightKg() *DoubleRange {
+	if x != nil {
+		return x.WeightKg
+	}
+	return nil
+}
+
+func (x *Filter) GetCpuNameContains() string {
+	if x != nil {
+		return x.CpuNameContains
+	}
+	return ""
+}
+
+func (x *Filter) GetGpuNameContains() string {
+	if x != nil {
+		return x.GpuNameContains
+	}
+	return ""
+}
+
+func (x *Filter) GetGpuMinGhz() *DoubleRange {
+	if x != nil {
+		return x.GpuMinGhz
+	}
+	return nil
+}
+
+func (x *Filter) GetGpuMaxGhz() *DoubleRange {
+	if x != nil {
+		return x.GpuMaxGhz
+	}
+	return nil
+}
+
 var File_filter_message_proto protoreflect.FileDescriptor
 
 var file_filter_message_proto_rawDesc = []byte{
@@ -98,22 +476,144 @@ var file_filter_message_proto_rawDesc = []byte{
 	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77,
 	0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x1a, 0x14,
 	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x22, 0xa9, 0x01, 0x0a, 0x06, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12,
-	0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x72, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x73, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69, 0x63, 0x65,
-	0x55, 0x73, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x69, 0x6e, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x63,
-	0x6f, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x6d, 0x69, 0x6e, 0x43,
-	0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0b, 0x6d, 0x69, 0x6e, 0x5f, 0x63,
-	0x70, 0x75, 0x5f, 0x67, 0x68, 0x7a, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x6d, 0x69,
-	0x6e, 0x43, 0x70, 0x75, 0x47, 0x68, 0x7a, 0x12, 0x37, 0x0a, 0x07, 0x6d, 0x69, 0x6e, 0x5f, 0x72,
-	0x61, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69,
+	0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x15, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x5f, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x14, 0x73, 0x63, 0x72,
+	0x65, 0x65, 0x6e, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x16, 0x6b, 0x65, 0x79, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x4b, 0x0a, 0x0b, 0x44, 0x6f, 0x75,
+	0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x15, 0x0a, 0x03, 0x6d, 0x69, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x88, 0x01, 0x01, 0x12,
+	0x15, 0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x03,
+	0x6d, 0x61, 0x78, 0x88, 0x01, 0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x69, 0x6e, 0x42, 0x06,
+	0x0a, 0x04, 0x5f, 0x6d, 0x61, 0x78, 0x22, 0x4b, 0x0a, 0x0b, 0x55, 0x49, 0x6e, 0x74, 0x33, 0x32,
+	0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x15, 0x0a, 0x03, 0x6d, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0d, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03,
+	0x6d, 0x61, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x03, 0x6d, 0x61, 0x78,
+	0x88, 0x01, 0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x69, 0x6e, 0x42, 0x06, 0x0a, 0x04, 0x5f,
+	0x6d, 0x61, 0x78, 0x22, 0x71, 0x0a, 0x0b, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x12, 0x30, 0x0a, 0x03, 0x6d, 0x69, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1e, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c,
+	0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52,
+	0x03, 0x6d, 0x69, 0x6e, 0x12, 0x30, 0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67,
+	0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72,
+	0x79, 0x52, 0x03, 0x6d, 0x61, 0x78, 0x22, 0xf2, 0x0d, 0x0a, 0x06, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x72, 0x69, 0x63, 0x65, 0x5f, 0x75,
+	0x73, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x50, 0x72, 0x69,
+	0x63, 0x65, 0x55, 0x73, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x6d, 0x69, 0x6e, 0x5f, 0x63, 0x70, 0x75,
+	0x5f, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x6d, 0x69,
+	0x6e, 0x43, 0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0b, 0x6d, 0x69, 0x6e,
+	0x5f, 0x63, 0x70, 0x75, 0x5f, 0x67, 0x68, 0x7a, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09,
+	0x6d, 0x69, 0x6e, 0x43, 0x70, 0x75, 0x47, 0x68, 0x7a, 0x12, 0x37, 0x0a, 0x07, 0x6d, 0x69, 0x6e,
+	0x5f, 0x72, 0x61, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x6c, 0x61,
+	0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67,
+	0x72, 0x70, 0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x52,
+	0x61, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x05, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x6e, 0x61,
+	0x6d, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0c, 0x6e, 0x61, 0x6d, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x12,
+	0x40, 0x0a, 0x09, 0x70, 0x72, 0x69, 0x63, 0x65, 0x5f, 0x75, 0x73, 0x64, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68,
+	0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x6f, 0x75, 0x62,
+	0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x08, 0x70, 0x72, 0x69, 0x63, 0x65, 0x55, 0x73,
+	0x64, 0x12, 0x46, 0x0a, 0x0c, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x5f, 0x79, 0x65, 0x61,
+	0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e,
+	0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63,
+	0x2e, 0x55, 0x49, 0x6e, 0x74, 0x33, 0x32, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x0b, 0x72, 0x65,
+	0x6c, 0x65, 0x61, 0x73, 0x65, 0x59, 0x65, 0x61, 0x72, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x70, 0x75,
+	0x5f, 0x62, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x63,
+	0x70, 0x75, 0x42, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x40, 0x0a, 0x09, 0x63, 0x70, 0x75, 0x5f,
+	0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c,
+	0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e,
+	0x67, 0x72, 0x70, 0x63, 0x2e, 0x55, 0x49, 0x6e, 0x74, 0x33, 0x32, 0x52, 0x61, 0x6e, 0x67, 0x65,
+	0x52, 0x08, 0x63, 0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73, 0x12, 0x44, 0x0a, 0x0b, 0x63, 0x70,
+	0x75, 0x5f, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c,
+	0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x55, 0x49, 0x6e, 0x74, 0x33, 0x32, 0x52,
+	0x61, 0x6e, 0x67, 0x65, 0x52, 0x0a, 0x63, 0x70, 0x75, 0x54, 0x68, 0x72, 0x65, 0x61, 0x64, 0x73,
+	0x12, 0x43, 0x0a, 0x0b, 0x63, 0x70, 0x75, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x67, 0x68, 0x7a, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77,
+	0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44,
+	0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x63, 0x70, 0x75, 0x4d,
+	0x69, 0x6e, 0x47, 0x68, 0x7a, 0x12, 0x43, 0x0a, 0x0b, 0x63, 0x70, 0x75, 0x5f, 0x6d, 0x61, 0x78,
+	0x5f, 0x67, 0x68, 0x7a, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61,
+	0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67,
+	0x72, 0x70, 0x63, 0x2e, 0x44, 0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52,
+	0x09, 0x63, 0x70, 0x75, 0x4d, 0x61, 0x78, 0x47, 0x68, 0x7a, 0x12, 0x35, 0x0a, 0x03, 0x72, 0x61,
+	0x6d, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e,
+	0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63,
+	0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x03, 0x72, 0x61,
+	0x6d, 0x12, 0x1c, 0x0a, 0x07, 0x68, 0x61, 0x73, 0x5f, 0x67, 0x70, 0x75, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x08, 0x48, 0x00, 0x52, 0x06, 0x68, 0x61, 0x73, 0x47, 0x70, 0x75, 0x88, 0x01, 0x01, 0x12,
+	0x1d, 0x0a, 0x0a, 0x67, 0x70, 0x75, 0x5f, 0x62, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x18, 0x10, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x09, 0x67, 0x70, 0x75, 0x42, 0x72, 0x61, 0x6e, 0x64, 0x73, 0x12, 0x42,
+	0x0a, 0x0a, 0x67, 0x70, 0x75, 0x5f, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x18, 0x11, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68,
+	0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f,
+	0x72, 0x79, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x67, 0x70, 0x75, 0x4d, 0x65, 0x6d, 0x6f,
+	0x72, 0x79, 0x12, 0x4f, 0x0a, 0x0f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x5f, 0x64, 0x72,
+	0x69, 0x76, 0x65, 0x72, 0x73, 0x18, 0x12, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x26, 0x2e, 0x70, 0x6c,
+	0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e,
+	0x67, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x2e, 0x44, 0x72, 0x69,
+	0x76, 0x65, 0x72, 0x52, 0x0e, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x44, 0x72, 0x69, 0x76,
+	0x65, 0x72, 0x73, 0x12, 0x4e, 0x0a, 0x10, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x5f, 0x63,
+	0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e,
+	0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e,
+	0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x52, 0x0f, 0x73, 0x74, 0x6f, 0x72, 0x61, 0x67, 0x65, 0x43, 0x61, 0x70, 0x61, 0x63,
+	0x69, 0x74, 0x79, 0x12, 0x4d, 0x0a, 0x10, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x5f, 0x73, 0x69,
+	0x7a, 0x65, 0x5f, 0x69, 0x6e, 0x63, 0x68, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e,
+	0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e,
+	0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44, 0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e,
+	0x67, 0x65, 0x52, 0x0e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x53, 0x69, 0x7a, 0x65, 0x49, 0x6e,
+	0x63, 0x68, 0x12, 0x46, 0x0a, 0x0c, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x5f, 0x77, 0x69, 0x64,
+	0x74, 0x68, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69,
 	0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70,
-	0x63, 0x2e, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x52, 0x06, 0x6d, 0x69, 0x6e, 0x52, 0x61, 0x6d,
-	0x42, 0x3e, 0x5a, 0x3c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63,
-	0x61, 0x69, 0x6f, 0x66, 0x65, 0x72, 0x6e, 0x61, 0x6e, 0x64, 0x65, 0x73, 0x30, 0x30, 0x2f, 0x70,
-	0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x2d, 0x77, 0x69, 0x74, 0x68, 0x2d, 0x67, 0x6f, 0x6c, 0x61,
-	0x6e, 0x67, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x70, 0x62,
-	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x63, 0x2e, 0x55, 0x49, 0x6e, 0x74, 0x33, 0x32, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x0b, 0x73,
+	0x63, 0x72, 0x65, 0x65, 0x6e, 0x57, 0x69, 0x64, 0x74, 0x68, 0x12, 0x48, 0x0a, 0x0d, 0x73, 0x63,
+	0x72, 0x65, 0x65, 0x6e, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x16, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67,
+	0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x55, 0x49, 0x6e, 0x74, 0x33,
+	0x32, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x0c, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x48, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x12, 0x4c, 0x0a, 0x0e, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x5f, 0x70,
+	0x61, 0x69, 0x6e, 0x65, 0x6c, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x25, 0x2e, 0x70,
+	0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x2e, 0x50, 0x61, 0x69,
+	0x6e, 0x65, 0x6c, 0x52, 0x0d, 0x73, 0x63, 0x72, 0x65, 0x65, 0x6e, 0x50, 0x61, 0x69, 0x6e, 0x65,
+	0x6c, 0x73, 0x12, 0x52, 0x0a, 0x10, 0x6b, 0x65, 0x79, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x6c,
+	0x61, 0x79, 0x6f, 0x75, 0x74, 0x73, 0x18, 0x18, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x27, 0x2e, 0x70,
+	0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67,
+	0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x4b, 0x65, 0x79, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x2e, 0x4c,
+	0x61, 0x79, 0x6f, 0x75, 0x74, 0x52, 0x0f, 0x6b, 0x65, 0x79, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x4c,
+	0x61, 0x79, 0x6f, 0x75, 0x74, 0x73, 0x12, 0x2e, 0x0a, 0x10, 0x6b, 0x65, 0x79, 0x62, 0x6f, 0x61,
+	0x72, 0x64, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x6c, 0x69, 0x74, 0x18, 0x19, 0x20, 0x01, 0x28, 0x08,
+	0x48, 0x01, 0x52, 0x0f, 0x6b, 0x65, 0x79, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x42, 0x61, 0x63, 0x6b,
+	0x6c, 0x69, 0x74, 0x88, 0x01, 0x01, 0x12, 0x40, 0x0a, 0x09, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74,
+	0x5f, 0x6b, 0x67, 0x18, 0x1a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79,
+	0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x2e, 0x44, 0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x08,
+	0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x4b, 0x67, 0x12, 0x2a, 0x0a, 0x11, 0x63, 0x70, 0x75, 0x5f,
+	0x6e, 0x61, 0x6d, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x18, 0x1b, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0f, 0x63, 0x70, 0x75, 0x4e, 0x61, 0x6d, 0x65, 0x43, 0x6f, 0x6e, 0x74,
+	0x61, 0x69, 0x6e, 0x73, 0x12, 0x2a, 0x0a, 0x11, 0x67, 0x70, 0x75, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
+	0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0f, 0x67, 0x70, 0x75, 0x4e, 0x61, 0x6d, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73,
+	0x12, 0x43, 0x0a, 0x0b, 0x67, 0x70, 0x75, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x67, 0x68, 0x7a, 0x18,
+	0x1d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e, 0x67, 0x77,
+	0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x44,
+	0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x67, 0x70, 0x75, 0x4d,
+	0x69, 0x6e, 0x47, 0x68, 0x7a, 0x12, 0x43, 0x0a, 0x0b, 0x67, 0x70, 0x75, 0x5f, 0x6d, 0x61, 0x78,
+	0x5f, 0x67, 0x68, 0x7a, 0x18, 0x1e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x70, 0x6c, 0x61,
+	0x79, 0x69, 0x6e, 0x67, 0x77, 0x69, 0x74, 0x68, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x67,
+	0x72, 0x70, 0x63, 0x2e, 0x44, 0x6f, 0x75, 0x62, 0x6c, 0x65, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52,
+	0x09, 0x67, 0x70, 0x75, 0x4d, 0x61, 0x78, 0x47, 0x68, 0x7a, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x68,
+	0x61, 0x73, 0x5f, 0x67, 0x70, 0x75, 0x42, 0x13, 0x0a, 0x11, 0x5f, 0x6b, 0x65, 0x79, 0x62, 0x6f,
+	0x61, 0x72, 0x64, 0x5f, 0x62, 0x61, 0x63, 0x6b, 0x6c, 0x69, 0x74, 0x42, 0x3e, 0x5a, 0x3c, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x61, 0x69, 0x6f, 0x66, 0x65,
+	0x72, 0x6e, 0x61, 0x6e, 0x64, 0x65, 0x73, 0x30, 0x30, 0x2f, 0x70, 0x6c, 0x61, 0x79, 0x69, 0x6e,
+	0x67, 0x2d, 0x77, 0x69, 0x74, 0x68, 0x2d, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2f, 0x67, 0x72,
+	0x70, 0x63, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -128,18 +628,44 @@ func file_filter_message_proto_rawDescGZIP() []byte {
 	return file_filter_message_proto_rawDescData
 }
 
-var file_filter_message_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_filter_message_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_filter_message_proto_goTypes = []interface{}{
-	(*Filter)(nil), // 0: playingwithgolang.grpc.Filter
-	(*Memory)(nil), // 1: playingwithgolang.grpc.Memory
+	(*DoubleRange)(nil),  // 0: playingwithgolang.grpc.DoubleRange
+	(*UInt32Range)(nil),  // 1: playingwithgolang.grpc.UInt32Range
+	(*MemoryRange)(nil),  // 2: playingwithgolang.grpc.MemoryRange
+	(*Filter)(nil),       // 3: playingwithgolang.grpc.Filter
+	(*Memory)(nil),       // 4: playingwithgolang.grpc.Memory
+	(Storage_Driver)(0),  // 5: playingwithgolang.grpc.Storage.Driver
+	(Screen_Painel)(0),   // 6: playingwithgolang.grpc.Screen.Painel
+	(Keyboard_Layout)(0), // 7: playingwithgolang.grpc.Keyboard.Layout
 }
 var file_filter_message_proto_depIdxs = []int32{
-	1, // 0: playingwithgolang.grpc.Filter.min_ram:type_name -> playingwithgolang.grpc.Memory
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	4,  // 0: playingwithgolang.grpc.MemoryRange.min:type_name -> playingwithgolang.grpc.Memory
+	4,  // 1: playingwithgolang.grpc.MemoryRange.max:type_name -> playingwithgolang.grpc.Memory
+	4,  // 2: playingwithgolang.grpc.Filter.min_ram:type_name -> playingwithgolang.grpc.Memory
+	0,  // 3: playingwithgolang.grpc.Filter.price_usd:type_name -> playingwithgolang.grpc.DoubleRange
+	1,  // 4: playingwithgolang.grpc.Filter.release_year:type_name -> playingwithgolang.grpc.UInt32Range
+	1,  // 5: playingwithgolang.grpc.Filter.cpu_cores:type_name -> playingwithgolang.grpc.UInt32Range
+	1,  // 6: playingwithgolang.grpc.Filter.cpu_threads:type_name -> playingwithgolang.grpc.UInt32Range
+	0,  // 7: playingwithgolang.grpc.Filter.cpu_min_ghz:type_name -> playingwithgolang.grpc.DoubleRange
+	0,  // 8: playingwithgolang.grpc.Filter.cpu_max_ghz:type_name -> playingwithgolang.grpc.DoubleRange
+	2,  // 9: playingwithgolang.grpc.Filter.ram:type_name -> playingwithgolang.grpc.MemoryRange
+	2,  // 10: playingwithgolang.grpc.Filter.gpu_memory:type_name -> playingwithgolang.grpc.MemoryRange
+	5,  // 11: playingwithgolang.grpc.Filter.storage_drivers:type_name -> playingwithgolang.grpc.Storage.Driver
+	2,  // 12: playingwithgolang.grpc.Filter.storage_capacity:type_name -> playingwithgolang.grpc.MemoryRange
+	0,  // 13: playingwithgolang.grpc.Filter.screen_size_inch:type_name -> playingwithgolang.grpc.DoubleRange
+	1,  // 14: playingwithgolang.grpc.Filter.screen_width:type_name -> playingwithgolang.grpc.UInt32Range
+	1,  // 15: playingwithgolang.grpc.Filter.screen_height:type_name -> playingwithgolang.grpc.UInt32Range
+	6,  // 16: playingwithgolang.grpc.Filter.screen_painels:type_name -> playingwithgolang.grpc.Screen.Painel
+	7,  // 17: playingwithgolang.grpc.Filter.keyboard_layouts:type_name -> playingwithgolang.grpc.Keyboard.Layout
+	0,  // 18: playingwithgolang.grpc.Filter.weight_kg:type_name -> playingwithgolang.grpc.DoubleRange
+	0,  // 19: playingwithgolang.grpc.Filter.gpu_min_ghz:type_name -> playingwithgolang.grpc.DoubleRange
+	0,  // 20: playingwithgolang.grpc.Filter.gpu_max_ghz:type_name -> playingwithgolang.grpc.DoubleRange
+	21, // [21:21] is the sub-list for method output_type
+	21, // [21:21] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
 }
 
 func init() { file_filter_message_proto_init() }
@@ -148,8 +674,47 @@ func file_filter_message_proto_init() {
 		return
 	}
 	file_memory_message_proto_init()
+	file_storage_message_proto_init()
+	file_screen_message_proto_init()
+	file_keyboard_message_proto_init()
 	if !protoimpl.UnsafeEnabled {
 		file_filter_message_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DoubleRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_filter_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UInt32Range); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_filter_message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MemoryRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_filter_message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Filter); i {
 			case 0:
 				return &v.state
@@ -162,13 +727,16 @@ func file_filter_message_proto_init() {
 			}
 		}
 	}
+	file_filter_message_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	file_filter_message_proto_msgTypes[1].OneofWrappers = []interface{}{}
+	file_filter_message_proto_msgTypes[3].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_filter_message_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
 		},